@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/djwhocodes/ecom_cart_golang/notify"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var alertCollection *mongo.Collection = database.CollectionData(database.Client, "Alerts")
+
+// AlertNotifier delivers stock and price alerts. main swaps in the
+// configured notifier.
+var AlertNotifier notify.Notifier = notify.NewLog()
+
+func EnsureAlertIndexes(ctx context.Context) error {
+	return database.EnsureAlertIndexes(ctx, alertCollection)
+}
+
+func alertErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrCantFindAlert),
+		errors.Is(err, database.ErrCantFindProduct):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrInvalidAlert),
+		errors.Is(err, database.ErrCantFindVariant):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func alertError(c *gin.Context, err error) {
+	status := alertErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		c.JSON(status, gin.H{"error": "Error updating alerts"})
+		return
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// checkAlerts sends the alerts that a saved product now satisfies, including
+// those on bundles whose availability depends on it. It runs in the
+// background so the edit that triggered it isn't held up.
+func checkAlerts(product models.Product) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		products := []models.Product{product}
+		bundles, err := database.BundlesContaining(ctx, ProductCollection, product.ID)
+		if err != nil {
+			log.Println("error finding bundles for alerts:", err)
+		}
+		products = append(products, bundles...)
+
+		for _, p := range products {
+			due, err := database.DueAlerts(ctx, alertCollection, ProductCollection, p)
+			if err != nil {
+				log.Println("error checking alerts:", err)
+				continue
+			}
+			for _, alert := range due {
+				sendAlert(ctx, alert, p)
+			}
+		}
+	}()
+}
+
+// releaseStock hands reserved stock back and checks the alerts on the
+// products it went to, since a SKU that had sold out may now be back.
+func releaseStock(ctx context.Context, productCollection *mongo.Collection, items []models.FulfilmentItem) {
+	database.ReleaseVariantStock(ctx, productCollection, items)
+
+	released := map[primitive.ObjectID]bool{}
+	for _, item := range items {
+		if item.SKU == nil || released[item.Product_Id] {
+			continue
+		}
+		released[item.Product_Id] = true
+
+		product, err := database.GetProduct(ctx, productCollection, item.Product_Id)
+		if err != nil {
+			log.Println("error fetching product for alerts:", err)
+			continue
+		}
+		checkAlerts(product)
+	}
+}
+
+func sendAlert(ctx context.Context, alert models.Alert, product models.Product) {
+	now := time.Now()
+	claimed, err := database.ClaimAlert(ctx, alertCollection, alert.ID, now)
+	if err != nil {
+		log.Println("error claiming alert:", err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	name := "A product you're watching"
+	if product.Product_Name != nil {
+		name = *product.Product_Name
+	}
+	if alert.SKU != nil {
+		name += " (" + *alert.SKU + ")"
+	}
+
+	link := "/users/products/" + product.ID.Hex()
+	if product.Slug != "" {
+		link = "/users/products/" + product.Slug
+	}
+
+	message := notify.Message{
+		User_Id:    alert.User_Id,
+		Kind:       alert.Kind,
+		Link:       link,
+		Created_At: now,
+	}
+	switch alert.Kind {
+	case database.AlertBackInStock:
+		message.Subject = name + " is back in stock"
+		message.Body = name + " is available to order again."
+	case database.AlertPriceDrop:
+		state, err := database.CurrentAlertState(ctx, ProductCollection, product, alert.SKU)
+		if err != nil || state.Price == nil {
+			log.Println("error reading price for alert:", err)
+			database.ReleaseAlert(ctx, alertCollection, alert.ID)
+			return
+		}
+		message.Subject = "Price drop on " + name
+		message.Body = fmt.Sprintf("%s is now %d, at or below your target of %d.", name, *state.Price, *alert.Target_Price)
+	}
+
+	if err := AlertNotifier.Notify(ctx, message); err != nil {
+		log.Println("error sending alert:", err)
+		if err := database.ReleaseAlert(ctx, alertCollection, alert.ID); err != nil {
+			log.Println("error re-arming alert:", err)
+		}
+	}
+}
+
+func CreateAlert() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Query("userID")
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+			return
+		}
+
+		var alert models.Alert
+		if err := c.BindJSON(&alert); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validate := validator.New()
+		if validationErr := validate.Struct(alert); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		alert.User_Id = userID
+		if err := database.CreateAlert(ctx, alertCollection, ProductCollection, &alert); err != nil {
+			alertError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"alert": alert})
+	}
+}
+
+func ListAlerts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Query("userID")
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		alerts, err := database.ListAlerts(ctx, alertCollection, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching alerts"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"alerts": alerts})
+	}
+}
+
+func DeleteAlert() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Query("userID")
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+			return
+		}
+
+		alertID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert ID"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := database.DeleteAlert(ctx, alertCollection, userID, alertID); err != nil {
+			alertError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Alert deleted"})
+	}
+}
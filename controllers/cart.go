@@ -2,11 +2,13 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/models"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -50,13 +52,61 @@ func (app *Application) AddToCart() gin.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		err = database.AddProductToCart(ctx, app.productCollection, app.userCollection, productId, userQueryId)
+		sku := c.Query("sku")
+		if sku == "" {
+			var product models.Product
+			err = app.productCollection.FindOne(ctx, bson.M{"_id": productId}).Decode(&product)
+			if err == mongo.ErrNoDocuments {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": database.ErrCantFindProduct.Error()})
+				return
+			}
+			if err != nil {
+				log.Println("error fetching product:", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error fetching product"})
+				return
+			}
+			if !database.IsLive(product, time.Now()) {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": database.ErrProductNotAvailable.Error()})
+				return
+			}
+			if len(product.Variants) > 0 {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": database.ErrSKURequired.Error()})
+				return
+			}
+			if len(product.Bundle) > 0 {
+				bundle, err := database.ResolveBundle(ctx, app.productCollection, product)
+				if err != nil {
+					log.Println("error fetching bundle components:", err)
+					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error fetching bundle components"})
+					return
+				}
+				if bundle.Stock != nil && *bundle.Stock == 0 {
+					c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": database.ErrOutOfStock.Error()})
+					return
+				}
+			}
+
+			err = database.AddProductToCart(ctx, app.productCollection, app.userCollection, productId, userQueryId)
+		} else {
+			err = database.AddVariantToCart(ctx, app.productCollection, app.userCollection, productId, sku, userQueryId)
+		}
+		if errors.Is(err, database.ErrCantFindVariant) || errors.Is(err, database.ErrOutOfStock) ||
+			errors.Is(err, database.ErrProductNotAvailable) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			log.Println("error adding product to cart:", err)
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
+		if searchID := c.Query("search_id"); searchID != "" {
+			if err := database.RecordSearchOutcome(ctx, searchEventCollection, searchID, productId, true); err != nil {
+				log.Println("error recording search conversion:", err)
+			}
+		}
+
 		c.JSON(http.StatusOK, gin.H{"message": "product added to cart successfully"})
 	}
 }
@@ -84,7 +134,11 @@ func (app *Application) RemoveItem() gin.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		err = database.RemoveCartItem(ctx, app.userCollection, productId, userQueryId)
+		if sku := c.Query("sku"); sku != "" {
+			err = database.RemoveVariantFromCart(ctx, app.userCollection, productId, sku, userQueryId)
+		} else {
+			err = database.RemoveCartItem(ctx, app.userCollection, productId, userQueryId)
+		}
 		if err != nil {
 			log.Println("error removing product from cart:", err)
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -106,8 +160,52 @@ func (app *Application) BuyFromCart() gin.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		err := database.BuyItemFromCart(ctx, app.userCollection, userQueryId)
+		user, shipping, ok := app.checkDelivery(ctx, c, userQueryId, nil)
+		if !ok {
+			return
+		}
+		if len(user.User_Cart) == 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": database.ErrEmptyCart.Error()})
+			return
+		}
+
+		var billing *models.Address
+		if billingQueryId := c.Query("billing_address_id"); billingQueryId != "" {
+			address, err := database.UserAddress(user, billingQueryId)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "billing address not found"})
+				return
+			}
+			billing = &address
+		}
+
+		fulfilment, err := database.FulfilmentItems(ctx, app.productCollection, user.User_Cart)
+		if err != nil {
+			log.Println("error resolving fulfilment items:", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error resolving fulfilment items"})
+			return
+		}
+
+		err = database.ReserveVariantStock(ctx, app.productCollection, fulfilment)
+		if errors.Is(err, database.ErrOutOfStock) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Println("error reserving stock:", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error reserving stock"})
+			return
+		}
+
+		payment := models.Payment{Digital: c.Query("payment") != "cod", COD: c.Query("payment") == "cod"}
+		_, err = database.PlaceCartOrder(ctx, app.userCollection, userQueryId, user.User_Cart, payment, shipping, billing, fulfilment)
+		if errors.Is(err, database.ErrCartChanged) {
+			releaseStock(ctx, app.productCollection, fulfilment)
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
+			releaseStock(ctx, app.productCollection, fulfilment)
 			log.Println("error buying cart items:", err)
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -140,8 +238,84 @@ func (app *Application) InstantBuy() gin.HandlerFunc {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		err = database.InstantBuy(ctx, app.productCollection, app.userCollection, productId, userQueryId)
+		var product models.Product
+		err = app.productCollection.FindOne(ctx, bson.M{"_id": productId}).Decode(&product)
+		if err == mongo.ErrNoDocuments {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": database.ErrCantFindProduct.Error()})
+			return
+		}
 		if err != nil {
+			log.Println("error fetching product:", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error fetching product"})
+			return
+		}
+		if !database.IsLive(product, time.Now()) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": database.ErrProductNotAvailable.Error()})
+			return
+		}
+
+		line := models.ProductUser{
+			ID:           product.ID,
+			Product_Name: product.Product_Name,
+			Price:        product.Price,
+			Rating:       product.Rating,
+			Image:        product.Image,
+		}
+		if sku := c.Query("sku"); sku != "" {
+			variant, ok := database.FindVariant(product, sku)
+			if !ok {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": database.ErrCantFindVariant.Error()})
+				return
+			}
+			line = database.VariantCartLine(product, variant)
+		} else if len(product.Variants) > 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": database.ErrSKURequired.Error()})
+			return
+		}
+		if len(product.Bundle) > 0 {
+			bundle, err := database.ResolveBundle(ctx, app.productCollection, product)
+			if err != nil {
+				log.Println("error fetching bundle components:", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error fetching bundle components"})
+				return
+			}
+			if bundle.Stock != nil && *bundle.Stock == 0 {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": database.ErrOutOfStock.Error()})
+				return
+			}
+		}
+
+		_, shipping, ok := app.checkDelivery(ctx, c, userQueryId, []models.ProductUser{line})
+		if !ok {
+			return
+		}
+
+		fulfilment, err := database.FulfilmentItems(ctx, app.productCollection, []models.ProductUser{line})
+		if err != nil {
+			log.Println("error resolving fulfilment items:", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error resolving fulfilment items"})
+			return
+		}
+
+		err = database.ReserveVariantStock(ctx, app.productCollection, fulfilment)
+		if errors.Is(err, database.ErrOutOfStock) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Println("error reserving stock:", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error reserving stock"})
+			return
+		}
+
+		if line.SKU == nil && len(product.Bundle) == 0 {
+			err = database.InstantBuy(ctx, app.productCollection, app.userCollection, productId, userQueryId)
+		} else {
+			payment := models.Payment{Digital: c.Query("payment") != "cod", COD: c.Query("payment") == "cod"}
+			err = database.InstantBuyItem(ctx, app.userCollection, userQueryId, line, fulfilment, payment, shipping)
+		}
+		if err != nil {
+			releaseStock(ctx, app.productCollection, fulfilment)
 			log.Println("error performing instant buy:", err)
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -151,6 +325,66 @@ func (app *Application) InstantBuy() gin.HandlerFunc {
 	}
 }
 
+// checkDelivery resolves and returns the user and shipping address,
+// rejecting the purchase when the pincode isn't serviceable or COD was
+// requested but isn't allowed. A nil items slice means the user's cart is
+// being bought.
+func (app *Application) checkDelivery(ctx context.Context, c *gin.Context, userID string, items []models.ProductUser) (models.User, models.Address, bool) {
+	user, err := database.GetUser(ctx, app.userCollection, userID)
+	if errors.Is(err, database.ErrUserIdIsNotValid) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return user, models.Address{}, false
+	}
+	if err != nil {
+		log.Println("error fetching user:", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error fetching user"})
+		return user, models.Address{}, false
+	}
+
+	if items == nil {
+		items = user.User_Cart
+	}
+
+	address, _, ok := validateDelivery(ctx, c, user, c.Query("address_id"), c.Query("payment") == "cod", items)
+	return user, address, ok
+}
+
+func validateDelivery(ctx context.Context, c *gin.Context, user models.User, addressID string, cod bool, items []models.ProductUser) (models.Address, models.Serviceability, bool) {
+	address, err := database.UserAddress(user, addressID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return address, models.Serviceability{}, false
+	}
+
+	entry, err := database.ValidateDelivery(ctx, serviceabilityCollection, address, cod)
+	if errors.Is(err, database.ErrPincodeNotServiceable) || errors.Is(err, database.ErrCODNotAvailable) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return address, entry, false
+	}
+	if err != nil {
+		log.Println("error checking delivery:", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error checking delivery"})
+		return address, entry, false
+	}
+
+	if !cod {
+		return address, entry, true
+	}
+
+	reason, err := database.CheckCODEligibility(ctx, codRulesCollection, user, *address.Pincode, items)
+	if errors.Is(err, database.ErrCODNotEligible) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "reason": reason})
+		return address, entry, false
+	}
+	if err != nil {
+		log.Println("error checking cod eligibility:", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error checking cod eligibility"})
+		return address, entry, false
+	}
+
+	return address, entry, true
+}
+
 func GetItemFromCart() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID := c.Query("id")
@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var categoryCollection *mongo.Collection = database.CollectionData(database.Client, "Categories")
+
+func categoryErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrCantFindCategory), errors.Is(err, database.ErrCantFindProduct):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrDuplicateSlug),
+		errors.Is(err, database.ErrInvalidSlug),
+		errors.Is(err, database.ErrCategoryCycle),
+		errors.Is(err, database.ErrInvalidCategoryIds):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func AddCategory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var category models.Category
+		if err := c.BindJSON(&category); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validate := validator.New()
+		if validationErr := validate.Struct(category); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := database.CreateCategory(ctx, categoryCollection, &category); err != nil {
+			c.JSON(categoryErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":  "Category created successfully",
+			"category": category,
+		})
+	}
+}
+
+func EditCategory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		categoryObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+			return
+		}
+
+		var changes models.Category
+		if err := c.BindJSON(&changes); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validate := validator.New()
+		if validationErr := validate.Struct(changes); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		category, err := database.UpdateCategory(ctx, categoryCollection, categoryObjID, changes)
+		if err != nil {
+			c.JSON(categoryErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "Category updated successfully",
+			"category": category,
+		})
+	}
+}
+
+func AssignProductCategories() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		var body struct {
+			Categories []primitive.ObjectID `json:"categories"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		seen := make(map[primitive.ObjectID]bool, len(body.Categories))
+		categoryIDs := []primitive.ObjectID{}
+		for _, id := range body.Categories {
+			if !seen[id] {
+				seen[id] = true
+				categoryIDs = append(categoryIDs, id)
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		before, err := database.GetProduct(ctx, ProductCollection, productObjID)
+		if err == nil {
+			err = database.SetProductCategories(ctx, ProductCollection, categoryCollection, productObjID, categoryIDs)
+		}
+		if err != nil {
+			c.JSON(categoryErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		after := before
+		after.Categories = categoryIDs
+		recordProductChange(ctx, c, database.ProductRecategory, &before, &after)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Product categories updated successfully"})
+	}
+}
+
+func CategoryTree() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		categories, err := database.ListCategories(ctx, categoryCollection)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching categories"})
+			return
+		}
+
+		c.JSON(http.StatusOK, database.BuildCategoryTree(categories))
+	}
+}
+
+// BrowseCategory lists a page of the live products in a category and its
+// subcategories, with the same filters, sorts and facets as SearchProduct.
+func BrowseCategory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter, err := parseProductFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		page, err := parseProductPage(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		category, err := database.GetCategory(ctx, categoryCollection, bson.M{"slug": c.Param("slug")})
+		if err != nil {
+			c.JSON(categoryErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		filter.Categories, err = database.CategoryWithDescendants(ctx, categoryCollection, category.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching categories"})
+			return
+		}
+		filter.Live_At = time.Now()
+
+		results, err := database.FacetedProductSearch(ctx, ProductCollection, filter, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching products from database"})
+			return
+		}
+
+		response := gin.H{
+			"category": category,
+			"products": results.Products,
+			"facets":   results.Facets,
+			"total":    results.Total,
+			"limit":    page.Limit,
+		}
+		if results.Next_Cursor != "" {
+			response["next_cursor"] = results.Next_Cursor
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
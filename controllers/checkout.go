@@ -0,0 +1,386 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var checkoutCollection *mongo.Collection = database.CollectionData(database.Client, "CheckoutSessions")
+var couponCollection *mongo.Collection = database.CollectionData(database.Client, "Coupons")
+
+func checkoutErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrCheckoutNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrCheckoutExpired):
+		return http.StatusGone
+	case errors.Is(err, database.ErrCheckoutClosed):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrEmptyCart),
+		errors.Is(err, database.ErrUserIdIsNotValid),
+		errors.Is(err, database.ErrCheckoutIncomplete),
+		errors.Is(err, database.ErrInvalidShippingMethod),
+		errors.Is(err, database.ErrInvalidCoupon),
+		errors.Is(err, database.ErrCouponMinimumNotMet):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrProductNoLongerOffered),
+		errors.Is(err, database.ErrCartChanged),
+		errors.Is(err, database.ErrOutOfStock):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func abortCheckout(c *gin.Context, err error) {
+	status := checkoutErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		log.Println("checkout error:", err)
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+}
+
+func (app *Application) loadCheckout(ctx context.Context, c *gin.Context) (models.CheckoutSession, bool) {
+	userQueryId := c.Query("userID")
+	if userQueryId == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "user id is required"})
+		return models.CheckoutSession{}, false
+	}
+
+	session, err := database.GetCheckoutSession(ctx, checkoutCollection, c.Param("id"), userQueryId)
+	if err != nil {
+		abortCheckout(c, err)
+		return session, false
+	}
+
+	return session, true
+}
+
+// saveCheckout re-prices the session and persists it, responding with the
+// updated session.
+func (app *Application) saveCheckout(ctx context.Context, c *gin.Context, session *models.CheckoutSession) {
+	if err := database.PriceCheckoutSession(ctx, app.productCollection, couponCollection, session); err != nil {
+		abortCheckout(c, err)
+		return
+	}
+
+	if err := database.SaveCheckoutSession(ctx, checkoutCollection, session); err != nil {
+		abortCheckout(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+func (app *Application) StartCheckout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userQueryId := c.Query("userID")
+		if userQueryId == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "user id is required"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		session, err := database.CreateCheckoutSession(ctx, checkoutCollection, app.userCollection, userQueryId)
+		if err != nil {
+			abortCheckout(c, err)
+			return
+		}
+
+		app.saveCheckout(ctx, c, &session)
+	}
+}
+
+func (app *Application) GetCheckout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		session, ok := app.loadCheckout(ctx, c)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+func (app *Application) SetCheckoutAddress() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		addressQueryId := c.Query("address_id")
+		if addressQueryId == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "address id is required"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		session, ok := app.loadCheckout(ctx, c)
+		if !ok {
+			return
+		}
+
+		user, err := database.GetUser(ctx, app.userCollection, session.User_Id)
+		if err != nil {
+			abortCheckout(c, err)
+			return
+		}
+
+		cod := session.Payment_Method != nil && session.Payment_Method.COD
+		address, entry, ok := validateDelivery(ctx, c, user, addressQueryId, cod, session.Items)
+		if !ok {
+			return
+		}
+
+		session.Billing_Address_Id = nil
+		if billingQueryId := c.Query("billing_address_id"); billingQueryId != "" {
+			billing, err := database.UserAddress(user, billingQueryId)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "billing address not found"})
+				return
+			}
+			session.Billing_Address_Id = &billing.ID
+		}
+
+		estimated := database.EstimateDeliveryDate(time.Now(), entry.Transit_Days)
+		session.Address_Id = &address.ID
+		session.Estimated_Delivery = &estimated
+
+		app.saveCheckout(ctx, c, &session)
+	}
+}
+
+func (app *Application) SetCheckoutShipping() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		method := c.Query("method")
+		if _, ok := database.ShippingMethods[method]; !ok {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": database.ErrInvalidShippingMethod.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		session, ok := app.loadCheckout(ctx, c)
+		if !ok {
+			return
+		}
+
+		session.Shipping_Method = method
+
+		app.saveCheckout(ctx, c, &session)
+	}
+}
+
+func (app *Application) SetCheckoutCoupon() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		session, ok := app.loadCheckout(ctx, c)
+		if !ok {
+			return
+		}
+
+		if code := strings.TrimSpace(c.Query("code")); code != "" {
+			session.Coupon_Code = &code
+		} else {
+			session.Coupon_Code = nil
+		}
+
+		app.saveCheckout(ctx, c, &session)
+	}
+}
+
+func (app *Application) SetCheckoutPayment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		method := c.Query("method")
+		if method != "cod" && method != "digital" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "payment method must be 'cod' or 'digital'"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		session, ok := app.loadCheckout(ctx, c)
+		if !ok {
+			return
+		}
+
+		if method == "cod" {
+			if session.Address_Id == nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "shipping address must be set before choosing cash on delivery"})
+				return
+			}
+
+			if err := database.PriceCheckoutSession(ctx, app.productCollection, couponCollection, &session); err != nil {
+				abortCheckout(c, err)
+				return
+			}
+
+			user, err := database.GetUser(ctx, app.userCollection, session.User_Id)
+			if err != nil {
+				abortCheckout(c, err)
+				return
+			}
+
+			if _, _, ok := validateDelivery(ctx, c, user, session.Address_Id.Hex(), true, session.Items); !ok {
+				return
+			}
+		}
+
+		session.Payment_Method = &models.Payment{
+			Digital: method == "digital",
+			COD:     method == "cod",
+		}
+
+		app.saveCheckout(ctx, c, &session)
+	}
+}
+
+func (app *Application) ConfirmCheckout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		session, ok := app.loadCheckout(ctx, c)
+		if !ok {
+			return
+		}
+
+		if session.Address_Id == nil || session.Payment_Method == nil {
+			abortCheckout(c, database.ErrCheckoutIncomplete)
+			return
+		}
+
+		// Claim the session before touching stock so a repeated or concurrent
+		// confirm can't place a second order. Any failure below hands it back.
+		if err := database.ClaimCheckoutSession(ctx, checkoutCollection, session.ID); err != nil {
+			abortCheckout(c, err)
+			return
+		}
+		confirmed := false
+		defer func() {
+			if !confirmed {
+				if err := database.ReopenCheckoutSession(ctx, checkoutCollection, session.ID); err != nil {
+					log.Println("error reopening checkout session:", err)
+				}
+			}
+		}()
+
+		if err := database.PriceCheckoutSession(ctx, app.productCollection, couponCollection, &session); err != nil {
+			abortCheckout(c, err)
+			return
+		}
+
+		user, err := database.GetUser(ctx, app.userCollection, session.User_Id)
+		if err != nil {
+			abortCheckout(c, err)
+			return
+		}
+
+		shipping, _, ok := validateDelivery(ctx, c, user, session.Address_Id.Hex(), session.Payment_Method.COD, session.Items)
+		if !ok {
+			return
+		}
+
+		var billing *models.Address
+		if session.Billing_Address_Id != nil {
+			address, err := database.UserAddress(user, session.Billing_Address_Id.Hex())
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "billing address not found"})
+				return
+			}
+			billing = &address
+		}
+
+		fulfilment, err := database.FulfilmentItems(ctx, app.productCollection, session.Items)
+		if err != nil {
+			abortCheckout(c, err)
+			return
+		}
+
+		if err := database.ReserveVariantStock(ctx, app.productCollection, fulfilment); err != nil {
+			abortCheckout(c, err)
+			return
+		}
+
+		order, err := database.ConfirmCheckoutSession(ctx, checkoutCollection, app.userCollection, &session, shipping, billing, fulfilment)
+		if err != nil && order.ID.IsZero() {
+			releaseStock(ctx, app.productCollection, fulfilment)
+			abortCheckout(c, err)
+			return
+		}
+		confirmed = true
+		if err != nil {
+			// The order is placed; the session just wasn't marked confirmed
+			// and stays claimed.
+			log.Println("error closing checkout session:", err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "order placed successfully",
+			"order":   order,
+		})
+	}
+}
+
+func AddCoupon() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Coupons are redeemable unless the request says otherwise.
+		coupon := models.Coupon{Active: true}
+		if err := c.BindJSON(&coupon); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validate := validator.New()
+		if validationErr := validate.Struct(coupon); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		code := strings.ToUpper(strings.TrimSpace(*coupon.Code))
+		count, err := couponCollection.CountDocuments(ctx, bson.M{"code": code})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking coupon code"})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Coupon code already exists"})
+			return
+		}
+
+		coupon.Code = &code
+		coupon.Created_At = time.Now()
+
+		result, err := couponCollection.InsertOne(ctx, coupon)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Coupon creation failed"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Coupon created successfully",
+			"id":      result.InsertedID,
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var codRulesCollection *mongo.Collection = database.CollectionData(database.Client, "CODRules")
+
+func GetCODRules() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		rules, err := database.GetCODRules(ctx, codRulesCollection)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching COD rules"})
+			return
+		}
+
+		c.JSON(http.StatusOK, rules)
+	}
+}
+
+func UpdateCODRules() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var rules models.CODRules
+		if err := c.BindJSON(&rules); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validate := validator.New()
+		if validationErr := validate.Struct(rules); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := database.SaveCODRules(ctx, codRulesCollection, rules); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save COD rules"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "COD rules updated successfully"})
+	}
+}
+
+func (app *Application) RecordCODRefusal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Query("userID")
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err := database.RecordCODRefusal(ctx, app.userCollection, userID)
+		if errors.Is(err, database.ErrUserIdIsNotValid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "COD refusal recorded"})
+	}
+}
@@ -2,8 +2,11 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/djwhocodes/ecom_cart_golang/database"
@@ -74,8 +77,9 @@ func SignUp() gin.HandlerFunc {
 		user.Updated_At = time.Now()
 		user.ID = primitive.NewObjectID()
 		user.User_Id = user.ID.Hex()
+		user.User_Type = database.UserTypeUser
 
-		token, refreshToken, err := tokens.GenerateAllTokens(*user.Email, *user.First_Name, *user.Last_Name, user.User_Id)
+		token, refreshToken, err := tokens.GenerateAllTokens(*user.Email, *user.First_Name, *user.Last_Name, user.User_Id, user.User_Type)
 		if err != nil {
 			log.Println("Error generating tokens:", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
@@ -134,6 +138,7 @@ func Login() gin.HandlerFunc {
 			*foundUser.First_Name,
 			*foundUser.Last_Name,
 			foundUser.User_Id,
+			foundUser.User_Type,
 		)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation failed"})
@@ -170,87 +175,276 @@ func Login() gin.HandlerFunc {
 
 func ProductViewerAdmin() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var product models.Product
+
+		if err := c.BindJSON(&product); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		product.ID = primitive.NewObjectID()
+		// Ratings come from approved reviews only.
+		product.Rating = nil
+		product.Rating_Average = 0
+		product.Rating_Count = 0
+		// Images are added through the upload endpoint.
+		product.Images = nil
+
+		if !validateProduct(ctx, c, &product) {
+			return
+		}
 
+		_, insertErr := ProductCollection.InsertOne(ctx, product)
+		if insertErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Product creation failed: " + insertErr.Error()})
+			return
+		}
+
+		recordProductChange(ctx, c, database.ProductCreated, nil, &product)
+		indexProduct(product)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Product added successfully!",
+			"product": product,
+		})
 	}
 }
 
 func SearchProduct() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var productList []models.Product
+		filter, err := parseProductFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		page, err := parseProductPage(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		filter.Live_At = time.Now()
+
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		cursor, err := ProductCollection.Find(ctx, bson.M{})
+		results, err := database.FacetedProductSearch(ctx, ProductCollection, filter, page)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Error fetching products from database",
 			})
 			return
 		}
-		defer cursor.Close(ctx)
 
-		if err = cursor.All(ctx, &productList); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Error decoding product data",
-			})
-			return
+		response := gin.H{
+			"products": results.Products,
+			"facets":   results.Facets,
+			"total":    results.Total,
+			"limit":    page.Limit,
+		}
+		if results.Next_Cursor != "" {
+			response["next_cursor"] = results.Next_Cursor
 		}
 
-		if len(productList) == 0 {
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": "No products found",
-			})
-			return
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// parseLimit reads the limit query parameter.
+func parseLimit(c *gin.Context) (int64, error) {
+	limit := int64(database.DefaultPageSize)
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 1 {
+			return 0, fmt.Errorf("invalid value for limit")
+		}
+		limit = min(parsed, database.MaxPageSize)
+	}
+	return limit, nil
+}
+
+// parsePage reads the limit and offset cursor query parameters.
+func parsePage(c *gin.Context) (skip, limit int64, err error) {
+	limit, err = parseLimit(c)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	skip, err = database.DecodeCursor(c.Query("cursor"))
+	return skip, limit, err
+}
+
+func parseProductPage(c *gin.Context) (database.ProductPage, error) {
+	var page database.ProductPage
+
+	limit, err := parseLimit(c)
+	if err != nil {
+		return page, err
+	}
+	page.Limit = limit
+
+	sort, ok := database.ProductSorts[c.DefaultQuery("sort", database.DefaultProductSort)]
+	if !ok {
+		return page, database.ErrInvalidSort
+	}
+	page.Sort = sort
+
+	page.After, err = database.DecodeProductCursor(c.Query("cursor"), sort)
+	if err != nil {
+		return page, err
+	}
+
+	page.Fields, err = database.ParseProductFields(c.Query("fields"))
+	if err != nil {
+		return page, err
+	}
+
+	return page, nil
+}
+
+// parseProductFilter reads attribute filters of the form
+// attr[brand]=Acme,Globex and the min/max price and rating ranges.
+func parseProductFilter(c *gin.Context) (database.ProductFilter, error) {
+	filter := database.ProductFilter{Attributes: map[string][]string{}}
+
+	for name, raw := range c.QueryMap("attr") {
+		if name == "" || strings.ContainsAny(name, ".$") {
+			return filter, fmt.Errorf("invalid attribute name %q", name)
+		}
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				filter.Attributes[name] = append(filter.Attributes[name], value)
+			}
 		}
+	}
 
-		c.JSON(http.StatusOK, productList)
+	for _, bound := range []struct {
+		name string
+		bits int
+		set  func(uint64)
+	}{
+		{"min_price", 32, func(v uint64) { p := uint32(v); filter.Min_Price = &p }},
+		{"max_price", 32, func(v uint64) { p := uint32(v); filter.Max_Price = &p }},
+		{"min_rating", 8, func(v uint64) { r := uint8(v); filter.Min_Rating = &r }},
+		{"max_rating", 8, func(v uint64) { r := uint8(v); filter.Max_Rating = &r }},
+	} {
+		raw := c.Query(bound.name)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseUint(raw, 10, bound.bits)
+		if err != nil {
+			return filter, fmt.Errorf("invalid value for %s", bound.name)
+		}
+		bound.set(v)
 	}
+
+	return filter, nil
 }
 
 func SearchProductByQuery() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		query := c.Query("name")
-		if query == "" {
+		raw := c.Query("q")
+		if raw == "" {
+			raw = c.Query("name")
+		}
+
+		query := database.ParseSearchQuery(raw)
+		if query.Empty() {
 			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Query parameter 'name' is required",
+				"error": "Query parameter 'q' is required",
 			})
 			return
 		}
 
-		var productList []models.Product
+		skip, limit, err := parsePage(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
 
-		filter := bson.M{
-			"name": bson.M{
-				"$regex":   query,
-				"$options": "i",
-			},
+		// A search is recorded once, on its first page. Later pages carry
+		// the search_id the first page returned.
+		firstPage := c.Query("cursor") == ""
+
+		if rule, ok := SearchRules.Redirect(raw); ok {
+			category, err := database.GetCategory(ctx, categoryCollection, bson.M{"_id": *rule.Category_Id})
+			if err == nil {
+				searchID, err := database.RecordSearchEvent(ctx, searchEventCollection, raw, 0, c.Query("userID"), category.Slug)
+				if err != nil {
+					log.Println("error recording search event:", err)
+				}
+
+				c.JSON(http.StatusOK, gin.H{
+					"query":     raw,
+					"search_id": searchID,
+					"redirect": gin.H{
+						"category": category.Slug,
+						"url":      "/users/categories/" + category.Slug + "/products",
+					},
+				})
+				return
+			}
 		}
 
-		cursor, err := ProductCollection.Find(ctx, filter)
+		ranked, err := rankProducts(ctx, raw, query, c.Query("match") == "all")
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": "Error searching for products",
 			})
 			return
 		}
-		defer cursor.Close(ctx)
 
-		if err = cursor.All(ctx, &productList); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Error decoding product data",
+		total := int64(len(ranked))
+		searchID := c.Query("search_id")
+		if firstPage {
+			id, err := database.RecordSearchEvent(ctx, searchEventCollection, raw, total, c.Query("userID"), "")
+			if err != nil {
+				log.Println("error recording search event:", err)
+			}
+			searchID = id.Hex()
+		}
+
+		if total == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"message":   "No matching products found",
+				"search_id": searchID,
 			})
 			return
 		}
 
-		if len(productList) == 0 {
-			c.JSON(http.StatusNotFound, gin.H{
-				"message": "No matching products found",
+		hits, next, err := searchPage(ctx, query, ranked, skip, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Error searching for products",
 			})
 			return
 		}
 
-		c.JSON(http.StatusOK, productList)
+		if firstPage {
+			if err := database.RecordSearchQuery(ctx, searchQueryCollection, raw); err != nil {
+				log.Println("error recording search query:", err)
+			}
+		}
+
+		response := gin.H{
+			"query":     raw,
+			"search_id": searchID,
+			"results":   hits,
+			"total":     total,
+			"limit":     limit,
+		}
+		if next < total {
+			response["next_cursor"] = database.EncodeCursor(next)
+		}
+
+		c.JSON(http.StatusOK, response)
 	}
 }
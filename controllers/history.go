@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/djwhocodes/ecom_cart_golang/tokens"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var productHistoryCollection *mongo.Collection = database.CollectionData(database.Client, "ProductHistory")
+
+func EnsureProductHistoryIndexes(ctx context.Context) error {
+	return database.EnsureProductHistoryIndexes(ctx, productHistoryCollection)
+}
+
+// tokenUserID returns the user the request's token belongs to, or "" when
+// there is no valid token.
+func tokenUserID(c *gin.Context) string {
+	if claims, _ := tokens.ValidateTokens(c.GetHeader("token")); claims != nil {
+		if userID, ok := claims["user_id"].(string); ok {
+			return userID
+		}
+	}
+	return ""
+}
+
+// changedBy names who made an admin change, from the request's token.
+func changedBy(c *gin.Context) string {
+	if userID := tokenUserID(c); userID != "" {
+		return userID
+	}
+	return "unknown"
+}
+
+// recordProductChange adds to the product's history. A failure is logged
+// rather than failing the edit that has already been saved.
+func recordProductChange(ctx context.Context, c *gin.Context, action string, before, after *models.Product) {
+	err := database.RecordProductVersion(ctx, productHistoryCollection, action, changedBy(c), before, after, nil)
+	if err != nil {
+		log.Println("error recording product history:", err)
+	}
+}
+
+func ProductHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		skip, limit, err := parsePage(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		versions, total, err := database.ListProductVersions(ctx, productHistoryCollection, productObjID, skip, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching product history"})
+			return
+		}
+
+		response := gin.H{
+			"versions": versions,
+			"total":    total,
+			"limit":    limit,
+		}
+		if next := skip + int64(len(versions)); next < total {
+			response["next_cursor"] = database.EncodeCursor(next)
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// RevertProduct restores a product to how it was at a past version. Variant
+// stock is live inventory, so SKUs that still exist keep their current stock,
+// and uploaded images stay as they are.
+func RevertProduct() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		versionNumber, err := strconv.Atoi(c.Param("version"))
+		if err != nil || versionNumber < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		version, err := database.GetProductVersion(ctx, productHistoryCollection, productObjID, versionNumber)
+		if errors.Is(err, database.ErrCantFindVersion) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching product history"})
+			return
+		}
+
+		current, err := database.GetProduct(ctx, ProductCollection, productObjID)
+		if errors.Is(err, database.ErrCantFindProduct) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching product"})
+			return
+		}
+
+		target := version.Snapshot
+		target.ID = productObjID
+		target.Images = current.Images
+		database.SyncPrimaryImage(&target)
+		// Stock is live inventory, not history: SKUs keep what they have now,
+		// and SKUs the revert brings back start with none.
+		for i, variant := range target.Variants {
+			if live, ok := database.FindVariant(current, variant.SKU); ok {
+				target.Variants[i].Stock = live.Stock
+			} else {
+				none := 0
+				target.Variants[i].Stock = &none
+			}
+		}
+
+		if !validateProduct(ctx, c, &target) {
+			return
+		}
+		database.TrackSlugChange(current, &target)
+
+		reverted, err := database.RevertProduct(ctx, ProductCollection, target)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Product revert failed: " + err.Error()})
+			return
+		}
+
+		err = database.RecordProductVersion(ctx, productHistoryCollection, database.ProductReverted, changedBy(c), &current, &reverted, &versionNumber)
+		if err != nil {
+			log.Println("error recording product history:", err)
+		}
+
+		indexProduct(reverted)
+		checkAlerts(reverted)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Product reverted to version " + strconv.Itoa(versionNumber),
+			"product": reverted,
+		})
+	}
+}
@@ -0,0 +1,261 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/images"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/djwhocodes/ecom_cart_golang/storage"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ImageStorage holds uploaded product images. main swaps in the configured
+// backend.
+var ImageStorage storage.Storage = storage.NewLocal("uploads")
+
+// maxImageSize bounds an uploaded image file.
+const maxImageSize = 10 << 20
+
+// imageURLPrefix is where ServeImage is mounted.
+const imageURLPrefix = "/users/images/"
+
+func imageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrCantFindProduct), errors.Is(err, database.ErrCantFindImage):
+		return http.StatusNotFound
+	case errors.Is(err, images.ErrUnsupportedType), errors.Is(err, images.ErrTooManyPixels),
+		errors.Is(err, database.ErrTooManyImages), errors.Is(err, database.ErrInvalidImageOrder):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func deleteImageFiles(ctx context.Context, image models.ProductImage) {
+	keys := []string{image.Key}
+	for _, thumb := range image.Thumbnails {
+		keys = append(keys, thumb.Key)
+	}
+	for _, key := range keys {
+		if err := ImageStorage.Delete(ctx, key); err != nil {
+			log.Println("error deleting stored image:", err)
+		}
+	}
+}
+
+// UploadProductImage stores an image and its thumbnails and appends it to
+// the product's images. The first image becomes the product's primary image.
+func UploadProductImage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		file, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "An image file is required"})
+			return
+		}
+		if file.Size > maxImageSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Image is too large"})
+			return
+		}
+
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to read uploaded file"})
+			return
+		}
+		defer f.Close()
+
+		data, err := io.ReadAll(io.LimitReader(f, maxImageSize+1))
+		if err != nil || len(data) > maxImageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to read uploaded file"})
+			return
+		}
+
+		upload, err := images.Process(data)
+		if err != nil {
+			c.JSON(imageErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		before, err := database.GetProduct(ctx, ProductCollection, productObjID)
+		if err != nil {
+			c.JSON(imageErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		imageID := primitive.NewObjectID()
+		prefix := path.Join("products", productObjID.Hex(), imageID.Hex())
+		image := models.ProductImage{
+			ID:           imageID,
+			Key:          prefix + upload.Original.Ext,
+			URL:          imageURLPrefix + prefix + upload.Original.Ext,
+			Content_Type: upload.Original.Content_Type,
+			Width:        upload.Original.Width,
+			Height:       upload.Original.Height,
+			Size:         int64(len(data)),
+			Thumbnails:   []models.Thumbnail{},
+			Uploaded_At:  time.Now(),
+		}
+		if alt := strings.TrimSpace(c.PostForm("alt")); alt != "" {
+			image.Alt = &alt
+		}
+		for _, thumb := range upload.Thumbnails {
+			key := prefix + "_" + thumb.Name + thumb.Ext
+			image.Thumbnails = append(image.Thumbnails, models.Thumbnail{
+				Name:   thumb.Name,
+				Key:    key,
+				URL:    imageURLPrefix + key,
+				Width:  thumb.Width,
+				Height: thumb.Height,
+			})
+		}
+
+		err = ImageStorage.Put(ctx, image.Key, bytes.NewReader(data))
+		for i := 0; err == nil && i < len(upload.Thumbnails); i++ {
+			err = ImageStorage.Put(ctx, image.Thumbnails[i].Key, bytes.NewReader(upload.Thumbnails[i].Data))
+		}
+		if err != nil {
+			log.Println("error storing image:", err)
+			deleteImageFiles(ctx, image)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error storing image"})
+			return
+		}
+
+		product, err := database.AddProductImage(ctx, ProductCollection, productObjID, image)
+		if err != nil {
+			deleteImageFiles(ctx, image)
+			c.JSON(imageErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		recordProductChange(ctx, c, database.ProductReimaged, &before, &product)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Image uploaded successfully",
+			"image":   image,
+			"product": product,
+		})
+	}
+}
+
+func ReorderProductImages() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		var body struct {
+			Image_Ids []primitive.ObjectID `json:"image_ids"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		before, err := database.GetProduct(ctx, ProductCollection, productObjID)
+		if err != nil {
+			c.JSON(imageErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		product, err := database.ReorderProductImages(ctx, ProductCollection, productObjID, body.Image_Ids)
+		if err != nil {
+			c.JSON(imageErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		recordProductChange(ctx, c, database.ProductReimaged, &before, &product)
+
+		c.JSON(http.StatusOK, gin.H{"images": product.Images})
+	}
+}
+
+func DeleteProductImage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		imageID, err := primitive.ObjectIDFromHex(c.Param("image_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid image ID"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		before, err := database.GetProduct(ctx, ProductCollection, productObjID)
+		if err != nil {
+			c.JSON(imageErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		removed, product, err := database.RemoveProductImage(ctx, ProductCollection, productObjID, imageID)
+		if err != nil {
+			c.JSON(imageErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		deleteImageFiles(ctx, removed)
+		recordProductChange(ctx, c, database.ProductReimaged, &before, &product)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Image deleted successfully"})
+	}
+}
+
+// ServeImage serves stored images and thumbnails. Keys embed the image id,
+// so a stored file never changes and can be cached indefinitely.
+func ServeImage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimPrefix(c.Param("key"), "/")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		object, err := ImageStorage.Get(ctx, key)
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Image not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reading image"})
+			return
+		}
+		defer object.Close()
+
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		c.Header("Content-Type", object.Content_Type)
+
+		if seeker, ok := object.ReadCloser.(io.ReadSeeker); ok {
+			http.ServeContent(c.Writer, c.Request, path.Base(key), object.Modified_At, seeker)
+			return
+		}
+		c.DataFromReader(http.StatusOK, object.Size, object.Content_Type, object, nil)
+	}
+}
@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func productErrorStatus(err error) int {
+	switch {
+	case database.IsInvalidProduct(err):
+		return http.StatusBadRequest
+	case errors.Is(err, database.ErrCantFindProduct):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// validateProduct checks an admin-submitted product and fills in its derived
+// fields, responding with the error if it isn't valid.
+func validateProduct(ctx context.Context, c *gin.Context, product *models.Product) bool {
+	err := database.ValidateProduct(ctx, ProductCollection, product)
+	if err == nil {
+		return true
+	}
+
+	if status := productErrorStatus(err); status != http.StatusInternalServerError {
+		c.JSON(status, gin.H{"error": err.Error()})
+	} else {
+		c.JSON(status, gin.H{"error": "Error checking product: " + err.Error()})
+	}
+	return false
+}
+
+func UpdateProduct() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		var product models.Product
+		if err := c.BindJSON(&product); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		product.ID = productObjID
+
+		before, err := database.GetProduct(ctx, ProductCollection, productObjID)
+		if errors.Is(err, database.ErrCantFindProduct) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching product"})
+			return
+		}
+
+		// Leaving slug out keeps the current one rather than deriving a new
+		// one from the name.
+		if product.Slug == "" {
+			product.Slug = before.Slug
+		}
+
+		if !validateProduct(ctx, c, &product) {
+			return
+		}
+
+		product.Images = before.Images
+		database.SyncPrimaryImage(&product)
+		database.TrackSlugChange(before, &product)
+
+		update := bson.M{
+			"$set": bson.M{
+				"product_name":    product.Product_Name,
+				"sku":             product.SKU,
+				"slug":            product.Slug,
+				"previous_slugs":  product.Previous_Slugs,
+				"description":     product.Description,
+				"specifications":  product.Specifications,
+				"seo_title":       product.SEO_Title,
+				"seo_description": product.SEO_Description,
+				"price":           product.Price,
+				"image":           product.Image,
+				"options":         product.Options,
+				"variants":        product.Variants,
+				"bundle":          product.Bundle,
+				"attributes":      product.Attributes,
+				"search_text":     product.Search_Text,
+			},
+		}
+
+		var updated models.Product
+		err = ProductCollection.FindOneAndUpdate(ctx, bson.M{"_id": productObjID}, update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": database.ErrCantFindProduct.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Product update failed: " + err.Error()})
+			return
+		}
+
+		recordProductChange(ctx, c, database.ProductUpdated, &before, &updated)
+		indexProduct(updated)
+		checkAlerts(updated)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Product updated successfully",
+			"product": updated,
+		})
+	}
+}
+
+// SetProductStatus moves a product between draft, scheduled, published and
+// archived. Archive discontinued products rather than deleting them.
+func SetProductStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		var body struct {
+			Status       string     `json:"status" validate:"required,oneof=draft scheduled published archived"`
+			Publish_At   *time.Time `json:"publish_at"`
+			Unpublish_At *time.Time `json:"unpublish_at"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validate := validator.New()
+		if validationErr := validate.Struct(body); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		before, err := database.GetProduct(ctx, ProductCollection, productObjID)
+		if errors.Is(err, database.ErrCantFindProduct) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching product"})
+			return
+		}
+
+		product, err := database.SetProductStatus(ctx, ProductCollection, productObjID, body.Status, body.Publish_At, body.Unpublish_At)
+		if errors.Is(err, database.ErrInvalidPublishWindow) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, database.ErrCantFindProduct) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating product status"})
+			return
+		}
+
+		recordProductChange(ctx, c, database.ProductRestatus, &before, &product)
+		indexProduct(product)
+		checkAlerts(product)
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Product status updated",
+			"product": product,
+		})
+	}
+}
+
+func DeleteProduct() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var deleted models.Product
+		err = ProductCollection.FindOneAndDelete(ctx, bson.M{"_id": productObjID}).Decode(&deleted)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusNotFound, gin.H{"error": database.ErrCantFindProduct.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting product"})
+			return
+		}
+
+		recordProductChange(ctx, c, database.ProductDeleted, &deleted, nil)
+		ProductIndex.Remove(productObjID)
+		for _, image := range deleted.Images {
+			deleteImageFiles(ctx, image)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Product deleted successfully"})
+	}
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/markdown"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const seoDescriptionLength = 160
+
+// ProductDetail shows a live product by id or slug, with a bundle's
+// components and how many are in stock. A slug the product has since been
+// renamed away from redirects to its current one. Views by signed-in users
+// are remembered for their recently viewed list.
+func ProductDetail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		var product models.Product
+		var moved bool
+		productObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err == nil {
+			product, err = database.GetProduct(ctx, ProductCollection, productObjID)
+		} else {
+			product, moved, err = database.GetProductBySlug(ctx, ProductCollection, c.Param("id"))
+		}
+		if err == nil && !database.IsLive(product, time.Now()) {
+			err = database.ErrCantFindProduct
+		}
+		if errors.Is(err, database.ErrCantFindProduct) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching product"})
+			return
+		}
+
+		if moved {
+			c.Redirect(http.StatusMovedPermanently, "/users/products/"+product.Slug)
+			return
+		}
+
+		recordView(ctx, c, product.ID)
+
+		description := ""
+		if product.Description != nil {
+			description = *product.Description
+		}
+
+		seo := gin.H{
+			"description": truncate(markdown.PlainText(description), seoDescriptionLength),
+		}
+		if product.Product_Name != nil {
+			seo["title"] = *product.Product_Name
+		}
+		if product.SEO_Title != nil {
+			seo["title"] = *product.SEO_Title
+		}
+		if product.SEO_Description != nil {
+			seo["description"] = *product.SEO_Description
+		}
+		if product.Slug != "" {
+			seo["canonical_path"] = "/users/products/" + product.Slug
+		}
+
+		response := gin.H{
+			"product":          product,
+			"description_html": markdown.Render(description),
+			"seo":              seo,
+		}
+		if len(product.Bundle) > 0 {
+			bundle, err := database.ResolveBundle(ctx, ProductCollection, product)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching bundle components"})
+				return
+			}
+			response["bundle"] = bundle
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// truncate cuts s to at most n characters, at a word boundary if it can.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+
+	cut := n - 1
+	for i := cut; i > n/2; i-- {
+		if runes[i] == ' ' {
+			cut = i
+			break
+		}
+	}
+	return string(runes[:cut]) + "…"
+}
@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var importJobCollection *mongo.Collection = database.CollectionData(database.Client, "ImportJobs")
+
+// maxImportSize bounds an uploaded import file, which is held in memory
+// until the job has run.
+const maxImportSize = 32 << 20
+
+// importTimeout bounds how long one import job may run.
+const importTimeout = 30 * time.Minute
+
+// FailInterruptedImports fails the import jobs a previous run of the server
+// left unfinished.
+func FailInterruptedImports(ctx context.Context) error {
+	failed, err := database.FailInterruptedImportJobs(ctx, importJobCollection)
+	if failed > 0 {
+		log.Println("marked interrupted import jobs as failed:", failed)
+	}
+	return err
+}
+
+// importFormat takes the format from the query, or else from the uploaded
+// file's extension.
+func importFormat(c *gin.Context, filename string) string {
+	if format := c.Query("format"); format != "" {
+		return strings.ToLower(format)
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return database.FormatCSV
+	case ".jsonl", ".ndjson":
+		return database.FormatJSONL
+	}
+	return ""
+}
+
+// ImportProducts queues a CSV or JSON Lines product import and returns the
+// job to poll. Rows are upserted by id, or by sku when they have no id; with
+// dry_run=true they are only checked.
+func ImportProducts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body io.Reader = c.Request.Body
+		filename := ""
+
+		if file, err := c.FormFile("file"); err == nil {
+			f, err := file.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to read uploaded file"})
+				return
+			}
+			defer f.Close()
+			body = f
+			filename = file.Filename
+		}
+
+		format := importFormat(c, filename)
+		if format != database.FormatCSV && format != database.FormatJSONL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": database.ErrUnknownFormat.Error()})
+			return
+		}
+
+		dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+		data, err := io.ReadAll(io.LimitReader(body, maxImportSize+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to read uploaded file"})
+			return
+		}
+		if len(data) > maxImportSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Import file is too large"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		job := models.ImportJob{
+			Format:     format,
+			Dry_Run:    dryRun,
+			Created_By: changedBy(c),
+		}
+		if err := database.CreateImportJob(ctx, importJobCollection, &job); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating import job"})
+			return
+		}
+
+		go runProductImport(job, data)
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"message": "Import queued",
+			"job":     job,
+		})
+	}
+}
+
+func runProductImport(job models.ImportJob, data []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), importTimeout)
+	defer cancel()
+
+	update := func(status string, result database.ImportResult, failure error) {
+		if err := database.UpdateImportJob(ctx, importJobCollection, job.ID, status, result, failure); err != nil {
+			log.Println("error updating import job:", err)
+		}
+	}
+
+	update(database.ImportRunning, database.ImportResult{}, nil)
+
+	rows, err := database.NewProductRows(job.Format, bytes.NewReader(data))
+	if err != nil {
+		update(database.ImportFailed, database.ImportResult{}, err)
+		return
+	}
+
+	saved := func(before *models.Product, after models.Product) {
+		action := database.ProductCreated
+		if before != nil {
+			action = database.ProductUpdated
+		}
+		err := database.RecordProductVersion(ctx, productHistoryCollection, action, job.Created_By, before, &after, nil)
+		if err != nil {
+			log.Println("error recording product history:", err)
+		}
+		indexProduct(after)
+		checkAlerts(after)
+	}
+	progress := func(result database.ImportResult) {
+		update(database.ImportRunning, result, nil)
+	}
+
+	result, err := database.ImportProducts(ctx, ProductCollection, categoryCollection, rows, job.Dry_Run, saved, progress)
+	if err != nil {
+		log.Println("product import failed:", err)
+		update(database.ImportFailed, result, err)
+		return
+	}
+
+	update(database.ImportCompleted, result, nil)
+}
+
+func loadImportJob(ctx context.Context, c *gin.Context) (models.ImportJob, bool) {
+	jobID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import job ID"})
+		return models.ImportJob{}, false
+	}
+
+	job, err := database.GetImportJob(ctx, importJobCollection, jobID)
+	if errors.Is(err, database.ErrCantFindImportJob) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return job, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching import job"})
+		return job, false
+	}
+
+	return job, true
+}
+
+func GetImportJob() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		job, ok := loadImportJob(ctx, c)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// ImportErrorReport downloads the rows an import job rejected as CSV.
+func ImportErrorReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		job, ok := loadImportJob(ctx, c)
+		if !ok {
+			return
+		}
+
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", `attachment; filename="import-`+job.ID.Hex()+`-errors.csv"`)
+
+		writer := csv.NewWriter(c.Writer)
+		writer.Write([]string{"row", "sku", "error"})
+		for _, rowErr := range job.Errors {
+			writer.Write([]string{strconv.Itoa(rowErr.Row), rowErr.SKU, rowErr.Error})
+		}
+		writer.Flush()
+	}
+}
+
+// ExportProducts downloads the whole catalogue as CSV or JSON Lines, in the
+// same shape ImportProducts reads.
+func ExportProducts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		format := strings.ToLower(c.DefaultQuery("format", database.FormatCSV))
+		if format != database.FormatCSV && format != database.FormatJSONL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": database.ErrUnknownFormat.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+
+		contentType := "text/csv"
+		if format == database.FormatJSONL {
+			contentType = "application/x-ndjson"
+		}
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", `attachment; filename="products.`+format+`"`)
+
+		if err := database.ExportProducts(ctx, ProductCollection, format, c.Writer); err != nil {
+			log.Println("error exporting products:", err)
+			if !c.Writer.Written() {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error exporting products"})
+			}
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var questionCollection *mongo.Collection = database.CollectionData(database.Client, "Questions")
+
+func questionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrCantFindQuestion), errors.Is(err, database.ErrCantFindAnswer),
+		errors.Is(err, database.ErrCantFindProduct):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrAlreadyVoted):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrInvalidModerationStatus), errors.Is(err, database.ErrUserIdIsNotValid):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func abortQuestion(c *gin.Context, err error) {
+	status := questionErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		log.Println("error handling product question:", err)
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+}
+
+func (app *Application) AskQuestion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+			return
+		}
+
+		userID := c.Query("userID")
+		if userID == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "user id is empty"})
+			return
+		}
+
+		var question models.Question
+		if err := c.BindJSON(&question); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validate := validator.New()
+		if validationErr := validate.Struct(question); validationErr != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		count, err := ProductCollection.CountDocuments(ctx, bson.M{"_id": productID})
+		if err == nil && count == 0 {
+			err = database.ErrCantFindProduct
+		}
+		if err != nil {
+			abortQuestion(c, err)
+			return
+		}
+
+		user, err := database.GetUser(ctx, app.userCollection, userID)
+		if err == nil {
+			err = database.CreateQuestion(ctx, questionCollection, user, productID, &question)
+		}
+		if err != nil {
+			abortQuestion(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":  "Question submitted for moderation",
+			"question": question,
+		})
+	}
+}
+
+// AnswerQuestion lets a shopper answer a question. Answers from customers
+// who ordered the product carry a verified buyer badge.
+func (app *Application) AnswerQuestion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		questionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid question id"})
+			return
+		}
+
+		userID := c.Query("userID")
+		if userID == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "user id is empty"})
+			return
+		}
+
+		answer, ok := bindAnswer(c)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		user, err := database.GetUser(ctx, app.userCollection, userID)
+		if err != nil {
+			abortQuestion(c, err)
+			return
+		}
+
+		productID, err := database.GetQuestionProduct(ctx, questionCollection, questionID)
+		if err != nil {
+			abortQuestion(c, err)
+			return
+		}
+
+		_, answer.Verified_Buyer = database.PurchasedOrder(user, productID)
+		answer.User_Id = user.ID.Hex()
+		answer.Seller = false
+
+		if err := database.AddAnswer(ctx, questionCollection, questionID, &answer); err != nil {
+			abortQuestion(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Answer submitted for moderation",
+			"answer":  answer,
+		})
+	}
+}
+
+// AnswerAsSeller publishes an answer from the store, skipping moderation.
+func AnswerAsSeller() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		questionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID"})
+			return
+		}
+
+		answer, ok := bindAnswer(c)
+		if !ok {
+			return
+		}
+		answer.User_Id = ""
+		answer.Seller = true
+		answer.Verified_Buyer = false
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := database.AddAnswer(ctx, questionCollection, questionID, &answer); err != nil {
+			abortQuestion(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Answer published",
+			"answer":  answer,
+		})
+	}
+}
+
+func bindAnswer(c *gin.Context) (models.Answer, bool) {
+	var answer models.Answer
+	if err := c.BindJSON(&answer); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return answer, false
+	}
+
+	validate := validator.New()
+	if validationErr := validate.Struct(answer); validationErr != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+		return answer, false
+	}
+
+	return answer, true
+}
+
+// ProductQuestions lists the approved questions on a product and their
+// approved answers, most upvoted first.
+func ProductQuestions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		filter := bson.M{"product_id": productID, "status": database.ModerationApproved}
+		listQuestions(c, filter, true)
+	}
+}
+
+// ListQuestionsForModeration lists questions that are pending themselves or
+// have pending answers.
+func ListQuestionsForModeration() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := bson.M{"$or": bson.A{
+			bson.M{"status": database.ModerationPending},
+			bson.M{"answers.status": database.ModerationPending},
+		}}
+		listQuestions(c, filter, false)
+	}
+}
+
+func listQuestions(c *gin.Context, filter bson.M, approvedOnly bool) {
+	skip, limit, err := parsePage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	questions, total, err := database.ListQuestions(ctx, questionCollection, filter, skip, limit, approvedOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching questions"})
+		return
+	}
+
+	response := gin.H{
+		"questions": questions,
+		"total":     total,
+		"limit":     limit,
+	}
+	if next := skip + int64(len(questions)); next < total {
+		response["next_cursor"] = database.EncodeCursor(next)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func UpvoteQuestion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		questionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID"})
+			return
+		}
+
+		userID := c.Query("userID")
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		upvotes, err := database.VoteQuestion(ctx, questionCollection, questionID, userID)
+		if err != nil {
+			abortQuestion(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"upvotes": upvotes})
+	}
+}
+
+func UpvoteAnswer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		questionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID"})
+			return
+		}
+
+		answerID, err := primitive.ObjectIDFromHex(c.Param("answer_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid answer ID"})
+			return
+		}
+
+		userID := c.Query("userID")
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		upvotes, err := database.VoteAnswer(ctx, questionCollection, questionID, answerID, userID)
+		if err != nil {
+			abortQuestion(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"upvotes": upvotes})
+	}
+}
+
+func ModerateQuestion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		questionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID"})
+			return
+		}
+
+		var body struct {
+			Status string `json:"status"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := database.ModerateQuestion(ctx, questionCollection, questionID, body.Status); err != nil {
+			abortQuestion(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Question " + body.Status})
+	}
+}
+
+func ModerateAnswer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		questionID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID"})
+			return
+		}
+
+		answerID, err := primitive.ObjectIDFromHex(c.Param("answer_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid answer ID"})
+			return
+		}
+
+		var body struct {
+			Status string `json:"status"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := database.ModerateAnswer(ctx, questionCollection, questionID, answerID, body.Status); err != nil {
+			abortQuestion(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Answer " + body.Status})
+	}
+}
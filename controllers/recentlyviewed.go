@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var recentlyViewedCollection *mongo.Collection = database.CollectionData(database.Client, "RecentlyViewed")
+
+// recordView adds a product to the recently viewed list of the signed-in
+// user, if any. A failure is logged rather than failing the page.
+func recordView(ctx context.Context, c *gin.Context, productID primitive.ObjectID) {
+	userID := tokenUserID(c)
+	if userID == "" {
+		return
+	}
+
+	if err := database.RecordProductView(ctx, recentlyViewedCollection, userID, productID, time.Now()); err != nil {
+		log.Println("error recording product view:", err)
+	}
+}
+
+func RecordProductView() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		userID := c.Query("userID")
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		// Only live products are remembered, so unknown ids can't push real
+		// views out of the list.
+		product, err := database.GetProduct(ctx, ProductCollection, productObjID)
+		if err == nil && !database.IsLive(product, time.Now()) {
+			err = database.ErrCantFindProduct
+		}
+		if errors.Is(err, database.ErrCantFindProduct) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching product"})
+			return
+		}
+
+		if err := database.RecordProductView(ctx, recentlyViewedCollection, userID, productObjID, time.Now()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error recording product view"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Product view recorded"})
+	}
+}
+
+// RecentlyViewed lists the user's recently viewed products, newest first.
+func RecentlyViewed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Query("userID")
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+			return
+		}
+
+		limit := database.DefaultPageSize
+		if raw := c.Query("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid value for limit"})
+				return
+			}
+			limit = min(parsed, database.MaxRecentlyViewed)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		recent, err := database.RecentlyViewed(ctx, recentlyViewedCollection, ProductCollection, userID, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching recently viewed products"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"recently_viewed": recent})
+	}
+}
+
+// ClearRecentlyViewed empties the user's recently viewed list, or removes
+// just the product given by product_id.
+func ClearRecentlyViewed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Query("userID")
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+			return
+		}
+
+		var productID *primitive.ObjectID
+		if raw := c.Query("product_id"); raw != "" {
+			id, err := primitive.ObjectIDFromHex(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+				return
+			}
+			productID = &id
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := database.ClearRecentlyViewed(ctx, recentlyViewedCollection, userID, productID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error clearing recently viewed products"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Recently viewed products cleared"})
+	}
+}
@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var productAssociationCollection *mongo.Collection = database.CollectionData(database.Client, "ProductAssociations")
+
+// RefreshRelatedProducts rebuilds every product's frequently bought together
+// list from the users' orders and its similar products from the catalogue.
+func (app *Application) RefreshRelatedProducts(ctx context.Context) error {
+	computedAt := time.Now()
+
+	together, err := database.CoPurchases(ctx, app.userCollection)
+	if err != nil {
+		return err
+	}
+
+	cursor, err := ProductCollection.Find(ctx, database.LiveProductFilter(computedAt))
+	if err != nil {
+		return err
+	}
+	var products []models.Product
+	if err = cursor.All(ctx, &products); err != nil {
+		return database.ErrCantDecodeProducts
+	}
+	similar := database.SimilarProducts(products)
+
+	associations := make([]models.ProductAssociation, 0, len(products))
+	for _, product := range products {
+		if len(together[product.ID]) == 0 && len(similar[product.ID]) == 0 {
+			continue
+		}
+		associations = append(associations, models.ProductAssociation{
+			ID:                         product.ID,
+			Frequently_Bought_Together: together[product.ID],
+			Similar:                    similar[product.ID],
+			Computed_At:                computedAt,
+		})
+	}
+
+	return database.SaveProductAssociations(ctx, productAssociationCollection, associations, computedAt)
+}
+
+// SyncRelatedProducts runs RefreshRelatedProducts on start and then every
+// interval.
+func (app *Application) SyncRelatedProducts(interval time.Duration) {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		if err := app.RefreshRelatedProducts(ctx); err != nil {
+			log.Println("error rebuilding related products:", err)
+		}
+		cancel()
+
+		time.Sleep(interval)
+	}
+}
+
+func RelatedProducts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productObjID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		limit := database.MaxRelatedProducts
+		if raw := c.Query("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid value for limit"})
+				return
+			}
+			limit = min(parsed, database.MaxRelatedProducts)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		association, err := database.GetProductAssociation(ctx, productAssociationCollection, productObjID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching related products"})
+			return
+		}
+
+		together, err := database.RelatedProductList(ctx, ProductCollection, association.Frequently_Bought_Together, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching related products"})
+			return
+		}
+		similar, err := database.RelatedProductList(ctx, ProductCollection, association.Similar, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching related products"})
+			return
+		}
+
+		response := gin.H{
+			"frequently_bought_together": together,
+			"similar":                    similar,
+		}
+		if !association.Computed_At.IsZero() {
+			response["computed_at"] = association.Computed_At
+		}
+
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, response)
+	}
+}
@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var reviewCollection *mongo.Collection = database.CollectionData(database.Client, "Reviews")
+
+func EnsureReviewIndexes(ctx context.Context) error {
+	return database.EnsureReviewIndexes(ctx, reviewCollection)
+}
+
+func reviewErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrCantFindReview):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrNotPurchased):
+		return http.StatusForbidden
+	case errors.Is(err, database.ErrAlreadyReviewed), errors.Is(err, database.ErrAlreadyVoted):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrInvalidModerationStatus), errors.Is(err, database.ErrUserIdIsNotValid):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (app *Application) AddReview() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+			return
+		}
+
+		userID := c.Query("userID")
+		if userID == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "user id is empty"})
+			return
+		}
+
+		var review models.Review
+		if err := c.BindJSON(&review); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validate := validator.New()
+		if validationErr := validate.Struct(review); validationErr != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		user, err := database.GetUser(ctx, app.userCollection, userID)
+		if err == nil {
+			err = database.CreateReview(ctx, reviewCollection, user, productID, &review)
+		}
+		if err != nil {
+			status := reviewErrorStatus(err)
+			if status == http.StatusInternalServerError {
+				log.Println("error saving review:", err)
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Review submitted for moderation",
+			"review":  review,
+		})
+	}
+}
+
+// ProductReviews lists the approved reviews of a product, most helpful first.
+func ProductReviews() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		productID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		skip, limit, err := parsePage(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		filter := bson.M{"product_id": productID, "status": database.ModerationApproved}
+		reviews, total, err := database.ListReviews(ctx, reviewCollection, filter, skip, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching reviews"})
+			return
+		}
+
+		response := gin.H{
+			"reviews": reviews,
+			"total":   total,
+			"limit":   limit,
+		}
+		if next := skip + int64(len(reviews)); next < total {
+			response["next_cursor"] = database.EncodeCursor(next)
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func VoteReviewHelpful() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reviewID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+			return
+		}
+
+		userID := c.Query("userID")
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		votes, err := database.VoteReviewHelpful(ctx, reviewCollection, reviewID, userID)
+		if err != nil {
+			c.JSON(reviewErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"helpful_votes": votes})
+	}
+}
+
+// ListReviewsForModeration lists reviews by status, pending by default.
+func ListReviewsForModeration() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		skip, limit, err := parsePage(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		filter := bson.M{"status": c.DefaultQuery("status", database.ModerationPending)}
+		reviews, total, err := database.ListReviews(ctx, reviewCollection, filter, skip, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching reviews"})
+			return
+		}
+
+		response := gin.H{
+			"reviews": reviews,
+			"total":   total,
+			"limit":   limit,
+		}
+		if next := skip + int64(len(reviews)); next < total {
+			response["next_cursor"] = database.EncodeCursor(next)
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+func ModerateReview() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reviewID, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+			return
+		}
+
+		var body struct {
+			Status string `json:"status"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		review, err := database.ModerateReview(ctx, reviewCollection, ProductCollection, reviewID, body.Status)
+		if err != nil {
+			c.JSON(reviewErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Review " + review.Status,
+			"review":  review,
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func savedItemErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrCantFindCartItem),
+		errors.Is(err, database.ErrCantFindSavedItem),
+		errors.Is(err, database.ErrCantFindProduct):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrUserIdIsNotValid),
+		errors.Is(err, database.ErrCantFindVariant),
+		errors.Is(err, database.ErrSKURequired),
+		errors.Is(err, database.ErrOutOfStock),
+		errors.Is(err, database.ErrProductNotAvailable):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func abortSavedItem(c *gin.Context, err error) {
+	status := savedItemErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		log.Println("saved for later error:", err)
+	}
+	c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+}
+
+// cartLineQuery reads the user, product and optional SKU identifying a cart
+// or saved line from the query string.
+func cartLineQuery(c *gin.Context) (userID string, productID primitive.ObjectID, sku *string, ok bool) {
+	userID = c.Query("userID")
+	if userID == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "user id is required"})
+		return
+	}
+
+	productID, err := primitive.ObjectIDFromHex(c.Query("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+		return
+	}
+
+	if raw := c.Query("sku"); raw != "" {
+		sku = &raw
+	}
+	return userID, productID, sku, true
+}
+
+func (app *Application) SaveForLater() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, productID, sku, ok := cartLineQuery(c)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err := database.SaveForLater(ctx, app.userCollection, userID, productID, sku)
+		if err != nil {
+			abortSavedItem(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "item saved for later"})
+	}
+}
+
+func (app *Application) MoveToCart() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, productID, sku, ok := cartLineQuery(c)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err := database.MoveToCart(ctx, app.productCollection, app.userCollection, userID, productID, sku)
+		if err != nil {
+			abortSavedItem(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "item moved to cart"})
+	}
+}
+
+func (app *Application) RemoveSavedItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, productID, sku, ok := cartLineQuery(c)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err := database.RemoveSavedItem(ctx, app.userCollection, userID, productID, sku)
+		if err != nil {
+			abortSavedItem(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "saved item removed"})
+	}
+}
+
+func (app *Application) SavedForLater() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Query("userID")
+		if userID == "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "user id is required"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		user, err := database.GetUser(ctx, app.userCollection, userID)
+		if errors.Is(err, database.ErrUserIdIsNotValid) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Println("error fetching user:", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "error fetching user"})
+			return
+		}
+
+		saved := user.Saved_For_Later
+		if saved == nil {
+			saved = []models.ProductUser{}
+		}
+		c.JSON(http.StatusOK, gin.H{"saved_for_later": saved})
+	}
+}
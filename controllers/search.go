@@ -0,0 +1,279 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/djwhocodes/ecom_cart_golang/search"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ProductIndex = search.NewIndex()
+var Suggestions = search.NewSuggester()
+var SearchRules = search.NewRuleSet()
+
+var searchQueryCollection *mongo.Collection = database.CollectionData(database.Client, "SearchQueries")
+
+// popularQueryLimit caps how many past queries are offered as suggestions.
+const popularQueryLimit = 1000
+
+// minSuggestedSearches is how often a query must have been searched, with
+// results, before it is offered to everyone as a suggestion.
+const minSuggestedSearches = 5
+
+// orderWeight is how many views an order of a product counts for when
+// ranking suggestions.
+const orderWeight = 5
+
+// synonymWeight scales the scores of matches found only through a synonym.
+const synonymWeight = 0.9
+
+func RefreshProductIndex(ctx context.Context) error {
+	return ProductIndex.Rebuild(func() ([]models.Product, error) {
+		cursor, err := ProductCollection.Find(ctx, database.LiveProductFilter(time.Now()))
+		if err != nil {
+			return nil, err
+		}
+		defer cursor.Close(ctx)
+
+		var products []models.Product
+		if err = cursor.All(ctx, &products); err != nil {
+			return nil, database.ErrCantDecodeProducts
+		}
+		return products, nil
+	})
+}
+
+// indexProduct keeps a saved product's search index entry in step with
+// whether it is live.
+func indexProduct(product models.Product) {
+	if database.IsLive(product, time.Now()) {
+		ProductIndex.Put(product)
+	} else {
+		ProductIndex.Remove(product.ID)
+	}
+}
+
+// RefreshSuggestions rebuilds the suggestions. Products are weighted by
+// their orders and views, categories by the popularity of their products and
+// past queries by how often they were searched.
+func RefreshSuggestions(ctx context.Context) error {
+	var entries []search.Suggestion
+
+	orders, err := database.ProductOrderCounts(ctx, userCollection)
+	if err != nil {
+		return err
+	}
+	views, err := database.ProductViewCounts(ctx, recentlyViewedCollection)
+	if err != nil {
+		return err
+	}
+
+	cursor, err := ProductCollection.Find(ctx, database.LiveProductFilter(time.Now()),
+		options.Find().SetProjection(bson.M{"product_name": 1, "categories": 1}))
+	if err != nil {
+		return err
+	}
+	var products []models.Product
+	if err = cursor.All(ctx, &products); err != nil {
+		return database.ErrCantDecodeProducts
+	}
+	categoryWeights := map[primitive.ObjectID]float64{}
+	for _, product := range products {
+		weight := float64(orders[product.ID]*orderWeight + views[product.ID])
+		for _, categoryID := range product.Categories {
+			categoryWeights[categoryID] += weight
+		}
+		if product.Product_Name != nil {
+			entries = append(entries, search.Suggestion{
+				Text:   *product.Product_Name,
+				Type:   search.SuggestProduct,
+				ID:     product.ID.Hex(),
+				Weight: weight,
+			})
+		}
+	}
+
+	categories, err := database.ListCategories(ctx, categoryCollection)
+	if err != nil {
+		return err
+	}
+	for _, category := range categories {
+		entries = append(entries, search.Suggestion{
+			Text:   *category.Name,
+			Type:   search.SuggestCategory,
+			ID:     category.ID.Hex(),
+			Slug:   category.Slug,
+			Weight: categoryWeights[category.ID],
+		})
+	}
+
+	queries, err := database.PopularSearchQueries(ctx, searchQueryCollection, minSuggestedSearches, popularQueryLimit)
+	if err != nil {
+		return err
+	}
+	for _, query := range queries {
+		entries = append(entries, search.Suggestion{
+			Text:   query.Query,
+			Type:   search.SuggestQuery,
+			Weight: float64(query.Count),
+		})
+	}
+
+	Suggestions.Rebuild(entries)
+	return nil
+}
+
+func RefreshSearchRules(ctx context.Context) error {
+	sets, err := database.ListSynonymSets(ctx, synonymCollection)
+	if err != nil {
+		return err
+	}
+
+	rules, err := database.ListQueryRules(ctx, queryRuleCollection)
+	if err != nil {
+		return err
+	}
+
+	SearchRules.Load(sets, rules)
+	return nil
+}
+
+// SyncSearchIndexes rebuilds the product index, suggestions and search rules
+// on start and then every interval, to pick up changes made outside the
+// admin endpoints.
+func SyncSearchIndexes(interval time.Duration) {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		if err := RefreshProductIndex(ctx); err != nil {
+			log.Println("error rebuilding product search index:", err)
+		}
+		if err := RefreshSuggestions(ctx); err != nil {
+			log.Println("error rebuilding search suggestions:", err)
+		}
+		if err := RefreshSearchRules(ctx); err != nil {
+			log.Println("error loading search rules:", err)
+		}
+		cancel()
+
+		time.Sleep(interval)
+	}
+}
+
+func SuggestSearch() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := 8
+		if raw := c.Query("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid value for limit"})
+				return
+			}
+			limit = min(parsed, search.MaxSuggestions)
+		}
+
+		c.Header("Cache-Control", "public, max-age=60")
+		c.JSON(http.StatusOK, gin.H{
+			"suggestions": Suggestions.Suggest(c.Query("q"), limit),
+		})
+	}
+}
+
+// rankProducts ranks every product matching query. Synonyms expand the
+// plain terms, the in-process index answers when it can with the database
+// text index as fallback, and then the query rules are applied.
+func rankProducts(ctx context.Context, raw string, query database.SearchQuery, matchAll bool) ([]search.Hit, error) {
+	var variants []database.SearchQuery
+	for _, terms := range SearchRules.Expand(strings.Join(query.Terms, " ")) {
+		variants = append(variants, database.SearchQuery{
+			Terms:   strings.Fields(terms),
+			Phrases: query.Phrases,
+			Exclude: query.Exclude,
+		})
+	}
+
+	var lists [][]search.Hit
+	if ProductIndex.Ready() {
+		for _, variant := range variants {
+			lists = append(lists, ProductIndex.Search(variant.Terms, variant.Phrases, variant.Exclude, matchAll))
+		}
+	}
+	ranked := search.MergeHits(lists, synonymWeight)
+
+	if len(ranked) == 0 {
+		lists = nil
+		for _, variant := range variants {
+			hits, err := database.TextSearchRanking(ctx, ProductCollection, variant, matchAll)
+			if err != nil {
+				return nil, err
+			}
+			lists = append(lists, hits)
+		}
+		ranked = search.MergeHits(lists, synonymWeight)
+	}
+
+	return search.ApplyRules(ranked, SearchRules.Rules(raw)), nil
+}
+
+// searchPage loads the products for one page of ranked hits, skipping any
+// that have since been deleted or taken down. It also returns the offset the
+// next page starts at.
+func searchPage(ctx context.Context, query database.SearchQuery, ranked []search.Hit, skip, limit int64) ([]database.SearchHit, int64, error) {
+	page := rankedPage(ranked, skip, limit)
+	if len(page) == 0 {
+		return []database.SearchHit{}, skip, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(page))
+	for _, hit := range page {
+		ids = append(ids, hit.ID)
+	}
+
+	products, err := database.FindProductsByIDs(ctx, ProductCollection, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	hits, next := pageHits(query, ranked, skip, limit, products, time.Now())
+	return hits, next, nil
+}
+
+func rankedPage(ranked []search.Hit, skip, limit int64) []search.Hit {
+	total := int64(len(ranked))
+	if skip >= total {
+		return nil
+	}
+	return ranked[skip:min(skip+limit, total)]
+}
+
+// pageHits pairs one page of ranked hits with their live products. The next
+// page starts after every ranked entry this page used, including dropped
+// ones, so dropped products never hold the cursor back.
+func pageHits(query database.SearchQuery, ranked []search.Hit, skip, limit int64, products map[primitive.ObjectID]models.Product, now time.Time) ([]database.SearchHit, int64) {
+	page := rankedPage(ranked, skip, limit)
+
+	hits := make([]database.SearchHit, 0, len(page))
+	for _, hit := range page {
+		product, ok := products[hit.ID]
+		if !ok || !database.IsLive(product, now) {
+			continue
+		}
+		hits = append(hits, database.SearchHit{
+			Product:    product,
+			Score:      hit.Score,
+			Highlights: database.HighlightProduct(query, product),
+		})
+	}
+
+	return hits, skip + int64(len(page))
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/djwhocodes/ecom_cart_golang/search"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPageHitsSkipsProductsThatAreNotLive(t *testing.T) {
+	now := time.Now()
+	ended := now.Add(-time.Hour)
+
+	ranked := make([]search.Hit, 6)
+	for i := range ranked {
+		ranked[i] = search.Hit{ID: primitive.NewObjectID(), Score: float64(len(ranked) - i)}
+	}
+
+	products := map[primitive.ObjectID]models.Product{
+		ranked[0].ID: {ID: ranked[0].ID, Status: database.ProductDraft},
+		ranked[1].ID: {ID: ranked[1].ID, Status: database.ProductArchived},
+		// ranked[2] has been deleted.
+		ranked[3].ID: {ID: ranked[3].ID, Status: database.ProductPublished},
+		ranked[4].ID: {ID: ranked[4].ID, Status: database.ProductPublished, Unpublish_At: &ended},
+		ranked[5].ID: {ID: ranked[5].ID},
+	}
+
+	tests := []struct {
+		name  string
+		skip  int64
+		limit int64
+		want  []primitive.ObjectID
+		next  int64
+	}{
+		{name: "whole page not live", skip: 0, limit: 2, want: []primitive.ObjectID{}, next: 2},
+		{name: "deleted and live", skip: 2, limit: 2, want: []primitive.ObjectID{ranked[3].ID}, next: 4},
+		{name: "last page", skip: 4, limit: 2, want: []primitive.ObjectID{ranked[5].ID}, next: 6},
+		{name: "short last page", skip: 5, limit: 4, want: []primitive.ObjectID{ranked[5].ID}, next: 6},
+		{name: "past the end", skip: 6, limit: 2, want: []primitive.ObjectID{}, next: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, next := pageHits(database.SearchQuery{}, ranked, tt.skip, tt.limit, products, now)
+
+			got := []primitive.ObjectID{}
+			for _, hit := range hits {
+				got = append(got, hit.Product.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pageHits() hits = %v, want %v", got, tt.want)
+			}
+			if next != tt.next {
+				t.Errorf("pageHits() next = %d, want %d", next, tt.next)
+			}
+		})
+	}
+}
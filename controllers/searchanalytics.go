@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var searchEventCollection *mongo.Collection = database.CollectionData(database.Client, "SearchEvents")
+
+// defaultReportDays is the window reported on when no from date is given.
+const defaultReportDays = 30
+
+// RecordSearchClick notes that a product was opened from a search result,
+// using the search_id returned with the results.
+func RecordSearchClick() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		searchID := c.Query("search_id")
+		if searchID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "search_id is required"})
+			return
+		}
+
+		productID, err := primitive.ObjectIDFromHex(c.Query("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product id"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err = database.RecordSearchOutcome(ctx, searchEventCollection, searchID, productID, false)
+		if errors.Is(err, database.ErrCantFindSearchEvent) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error recording click"})
+			return
+		}
+
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// SearchReport summarises searches between from and to (YYYY-MM-DD, to is
+// inclusive), defaulting to the last 30 days.
+func SearchReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now().UTC()
+		to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		if raw := c.Query("to"); raw != "" {
+			parsed, err := time.Parse(time.DateOnly, raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid value for to"})
+				return
+			}
+			to = parsed
+		}
+		to = to.AddDate(0, 0, 1)
+
+		from := to.AddDate(0, 0, -defaultReportDays)
+		if raw := c.Query("from"); raw != "" {
+			parsed, err := time.Parse(time.DateOnly, raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid value for from"})
+				return
+			}
+			from = parsed
+		}
+		if !from.Before(to) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+			return
+		}
+
+		limit := int64(20)
+		if raw := c.Query("limit"); raw != "" {
+			parsed, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || parsed < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid value for limit"})
+				return
+			}
+			limit = min(parsed, database.MaxPageSize)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		report, err := database.BuildSearchReport(ctx, searchEventCollection, from, to, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "error building search report"})
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
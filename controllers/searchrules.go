@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var synonymCollection *mongo.Collection = database.CollectionData(database.Client, "SearchSynonyms")
+var queryRuleCollection *mongo.Collection = database.CollectionData(database.Client, "SearchQueryRules")
+
+func reloadSearchRules(ctx context.Context) {
+	if err := RefreshSearchRules(ctx); err != nil {
+		log.Println("error loading search rules:", err)
+	}
+}
+
+func ListSynonyms() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		sets, err := database.ListSynonymSets(ctx, synonymCollection)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching synonyms"})
+			return
+		}
+
+		c.JSON(http.StatusOK, sets)
+	}
+}
+
+func AddSynonyms() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var set models.SynonymSet
+		if err := c.BindJSON(&set); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validate := validator.New()
+		if validationErr := validate.Struct(set); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := database.CreateSynonymSet(ctx, synonymCollection, &set); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save synonyms"})
+			return
+		}
+
+		reloadSearchRules(ctx)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message":  "Synonyms added successfully",
+			"synonyms": set,
+		})
+	}
+}
+
+func DeleteSynonyms() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err := database.DeleteByID(ctx, synonymCollection, c.Param("id"), database.ErrCantFindSynonymSet)
+		if errors.Is(err, database.ErrCantFindSynonymSet) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting synonyms"})
+			return
+		}
+
+		reloadSearchRules(ctx)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Synonyms deleted successfully"})
+	}
+}
+
+func ListQueryRules() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		rules, err := database.ListQueryRules(ctx, queryRuleCollection)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching query rules"})
+			return
+		}
+
+		c.JSON(http.StatusOK, rules)
+	}
+}
+
+func AddQueryRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Rules apply straight away unless the request says otherwise.
+		rule := models.QueryRule{Active: true}
+		if err := c.BindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validate := validator.New()
+		if validationErr := validate.Struct(rule); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err := database.CreateQueryRule(ctx, queryRuleCollection, &rule)
+		if errors.Is(err, database.ErrInvalidQueryRule) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save query rule"})
+			return
+		}
+
+		reloadSearchRules(ctx)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Query rule added successfully",
+			"rule":    rule,
+		})
+	}
+}
+
+func DeleteQueryRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err := database.DeleteByID(ctx, queryRuleCollection, c.Param("id"), database.ErrCantFindQueryRule)
+		if errors.Is(err, database.ErrCantFindQueryRule) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting query rule"})
+			return
+		}
+
+		reloadSearchRules(ctx)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Query rule deleted successfully"})
+	}
+}
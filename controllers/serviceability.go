@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var serviceabilityCollection *mongo.Collection = database.CollectionData(database.Client, "Serviceability")
+
+func ImportServiceability() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body io.Reader = c.Request.Body
+
+		if file, err := c.FormFile("file"); err == nil {
+			f, err := file.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to read uploaded file"})
+				return
+			}
+			defer f.Close()
+			body = f
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer cancel()
+
+		imported, rowErrors, err := database.ImportServiceabilityCSV(ctx, serviceabilityCollection, body)
+		if errors.Is(err, database.ErrInvalidServiceability) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error importing serviceability data"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "Serviceability data imported",
+			"imported": imported,
+			"errors":   rowErrors,
+		})
+	}
+}
+
+// CheckServiceability reports whether pincode can be delivered to. With a
+// product id (and optional sku) it also takes into account whether that
+// product is live and in stock, and whether the COD rules allow it.
+func CheckServiceability() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pincode := c.Query("pincode")
+		if pincode == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'pincode' is required"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		inStock, codOK, codReason := true, true, ""
+		productID := c.Query("id")
+		if productID != "" {
+			productObjID, err := primitive.ObjectIDFromHex(productID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+				return
+			}
+
+			product, err := database.GetProduct(ctx, ProductCollection, productObjID)
+			if err == nil && !database.IsLive(product, time.Now()) {
+				err = database.ErrCantFindProduct
+			}
+			if errors.Is(err, database.ErrCantFindProduct) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching product"})
+				return
+			}
+
+			var sku *string
+			if raw := c.Query("sku"); raw != "" {
+				sku = &raw
+			}
+			state, err := database.CurrentAlertState(ctx, ProductCollection, product, sku)
+			if errors.Is(err, database.ErrCantFindVariant) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking stock"})
+				return
+			}
+			inStock = state.Stock == nil || *state.Stock > 0
+
+			rules, err := database.GetCODRules(ctx, codRulesCollection)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking cod eligibility"})
+				return
+			}
+			// Refused deliveries are per user and checked at checkout.
+			line := models.ProductUser{ID: product.ID, Product_Name: product.Product_Name, Price: state.Price, SKU: sku}
+			codOK, codReason = database.EvaluateCOD(rules, models.User{}, pincode, []models.ProductUser{line})
+		}
+
+		entry, err := database.CheckPincode(ctx, serviceabilityCollection, pincode)
+		if errors.Is(err, database.ErrPincodeNotServiceable) {
+			c.JSON(http.StatusOK, gin.H{
+				"pincode":     pincode,
+				"deliverable": false,
+				"cod_allowed": false,
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking pincode"})
+			return
+		}
+
+		deliverable := entry.Deliverable && inStock
+		response := gin.H{
+			"pincode":            entry.Pincode,
+			"deliverable":        deliverable,
+			"cod_allowed":        deliverable && entry.COD_Allowed && codOK,
+			"transit_days":       entry.Transit_Days,
+			"estimated_delivery": database.EstimateDeliveryDate(time.Now(), entry.Transit_Days).Format("2006-01-02"),
+		}
+		if productID != "" {
+			response["in_stock"] = inStock
+			if codReason != "" {
+				response["cod_reason"] = codReason
+			}
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
@@ -0,0 +1,286 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var wishlistCollection *mongo.Collection = database.CollectionData(database.Client, "Wishlists")
+
+func wishlistErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, database.ErrCantFindWishlist),
+		errors.Is(err, database.ErrCantFindWishlistItem),
+		errors.Is(err, database.ErrCantFindProduct):
+		return http.StatusNotFound
+	case errors.Is(err, database.ErrCantFindVariant),
+		errors.Is(err, database.ErrTooManyWishlists),
+		errors.Is(err, database.ErrWishlistFull):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func wishlistError(c *gin.Context, err error) {
+	status := wishlistErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		c.JSON(status, gin.H{"error": "Error updating wishlist"})
+		return
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// wishlistRequest reads the owner from the query string and the wishlist
+// from the path.
+func wishlistRequest(c *gin.Context) (string, primitive.ObjectID, bool) {
+	userID := c.Query("userID")
+	if userID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+		return "", primitive.NilObjectID, false
+	}
+
+	wishlistID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid wishlist ID"})
+		return "", primitive.NilObjectID, false
+	}
+
+	return userID, wishlistID, true
+}
+
+// wishlistResponse shows a wishlist with its products.
+func wishlistResponse(ctx context.Context, c *gin.Context, wishlist models.Wishlist) {
+	items, err := database.WishlistProducts(ctx, ProductCollection, wishlist)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching wishlist products"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"wishlist": wishlist,
+		"items":    items,
+	})
+}
+
+func CreateWishlist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Query("userID")
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+			return
+		}
+
+		var wishlist models.Wishlist
+		if err := c.BindJSON(&wishlist); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validate := validator.New()
+		if validationErr := validate.Struct(wishlist); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		wishlist.User_Id = userID
+		if err := database.CreateWishlist(ctx, wishlistCollection, &wishlist); err != nil {
+			wishlistError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"wishlist": wishlist})
+	}
+}
+
+func ListWishlists() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.Query("userID")
+		if userID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "User ID is required"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		wishlists, err := database.ListWishlists(ctx, wishlistCollection, userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching wishlists"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"wishlists": wishlists})
+	}
+}
+
+func GetWishlist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, wishlistID, ok := wishlistRequest(c)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		wishlist, err := database.GetWishlist(ctx, wishlistCollection, userID, wishlistID)
+		if err != nil {
+			wishlistError(c, err)
+			return
+		}
+
+		wishlistResponse(ctx, c, wishlist)
+	}
+}
+
+// UpdateWishlist renames a wishlist or shares it. Shared wishlists get a
+// share_token for the public link; unsharing revokes it.
+func UpdateWishlist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, wishlistID, ok := wishlistRequest(c)
+		if !ok {
+			return
+		}
+
+		var body struct {
+			Name   *string `json:"name" validate:"omitempty,min=1,max=100"`
+			Public *bool   `json:"public"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validate := validator.New()
+		if validationErr := validate.Struct(body); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		wishlist, err := database.UpdateWishlist(ctx, wishlistCollection, userID, wishlistID, body.Name, body.Public)
+		if err != nil {
+			wishlistError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"wishlist": wishlist})
+	}
+}
+
+func DeleteWishlist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, wishlistID, ok := wishlistRequest(c)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := database.DeleteWishlist(ctx, wishlistCollection, userID, wishlistID); err != nil {
+			wishlistError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Wishlist deleted"})
+	}
+}
+
+func AddWishlistItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, wishlistID, ok := wishlistRequest(c)
+		if !ok {
+			return
+		}
+
+		var body struct {
+			Product_Id primitive.ObjectID `json:"product_id" validate:"required"`
+			SKU        *string            `json:"sku"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		validate := validator.New()
+		if validationErr := validate.Struct(body); validationErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		err := database.AddWishlistItem(ctx, wishlistCollection, ProductCollection, userID, wishlistID, body.Product_Id, body.SKU)
+		if err != nil {
+			wishlistError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Product added to wishlist"})
+	}
+}
+
+func RemoveWishlistItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, wishlistID, ok := wishlistRequest(c)
+		if !ok {
+			return
+		}
+
+		productObjID, err := primitive.ObjectIDFromHex(c.Param("product_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid product ID"})
+			return
+		}
+
+		var sku *string
+		if raw := c.Query("sku"); raw != "" {
+			sku = &raw
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if err := database.RemoveWishlistItem(ctx, wishlistCollection, userID, wishlistID, productObjID, sku); err != nil {
+			wishlistError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Product removed from wishlist"})
+	}
+}
+
+// SharedWishlist shows a public wishlist to anyone with its share link,
+// without revealing whose it is.
+func SharedWishlist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		wishlist, err := database.GetSharedWishlist(ctx, wishlistCollection, c.Param("token"))
+		if err != nil {
+			wishlistError(c, err)
+			return
+		}
+
+		wishlist.User_Id = ""
+		wishlistResponse(ctx, c, wishlist)
+	}
+}
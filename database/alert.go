@@ -0,0 +1,242 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrCantFindAlert = errors.New("can't find the alert")
+	ErrInvalidAlert  = errors.New("invalid alert")
+)
+
+const (
+	AlertBackInStock = "back_in_stock"
+	AlertPriceDrop   = "price_drop"
+)
+
+func EnsureAlertIndexes(ctx context.Context, alertCollection *mongo.Collection) error {
+	_, err := alertCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "active", Value: 1}}},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	return err
+}
+
+// AlertState is the price and stock an alert watches. Stock is nil when the
+// product doesn't track it.
+type AlertState struct {
+	Price *uint32
+	Stock *int
+}
+
+// CurrentAlertState reads the price and stock of a SKU, or of the product as
+// a whole. For a product with variants that is the lowest price of any SKU
+// in stock and the total stock over its variants; for a bundle, the stock is
+// the number of whole bundles available.
+func CurrentAlertState(ctx context.Context, productCollection *mongo.Collection, product models.Product, sku *string) (AlertState, error) {
+	state := AlertState{Price: product.Price}
+
+	switch {
+	case sku != nil:
+		variant, ok := FindVariant(product, *sku)
+		if !ok {
+			return state, ErrCantFindVariant
+		}
+		state.Price = variant.Price
+		state.Stock = variant.Stock
+	case len(product.Variants) > 0:
+		total := 0
+		state.Price = nil
+		for _, variant := range product.Variants {
+			if variant.Stock == nil || *variant.Stock <= 0 {
+				continue
+			}
+			total += *variant.Stock
+			if variant.Price != nil && (state.Price == nil || *variant.Price < *state.Price) {
+				state.Price = variant.Price
+			}
+		}
+		state.Stock = &total
+	case len(product.Bundle) > 0:
+		bundle, err := ResolveBundle(ctx, productCollection, product)
+		if err != nil {
+			return state, err
+		}
+		state.Stock = bundle.Stock
+	}
+
+	return state, nil
+}
+
+// alertDue reports whether an alert's condition holds for a live product.
+func alertDue(alert models.Alert, state AlertState) bool {
+	switch alert.Kind {
+	case AlertBackInStock:
+		return state.Stock != nil && *state.Stock > 0
+	case AlertPriceDrop:
+		return state.Price != nil && alert.Target_Price != nil && *state.Price <= *alert.Target_Price
+	default:
+		return false
+	}
+}
+
+// CreateAlert subscribes the user to a product. Alerts that would fire
+// straight away are refused, and asking again for the same product, SKU
+// and kind updates the existing alert.
+func CreateAlert(ctx context.Context, alertCollection, productCollection *mongo.Collection, alert *models.Alert) error {
+	product, err := GetProduct(ctx, productCollection, alert.Product_Id)
+	if err != nil {
+		return err
+	}
+
+	state, err := CurrentAlertState(ctx, productCollection, product, alert.SKU)
+	if err != nil {
+		return err
+	}
+
+	live := IsLive(product, time.Now())
+	switch {
+	case alert.Kind == AlertBackInStock && state.Stock == nil:
+		return fmt.Errorf("%w: the product's stock isn't tracked", ErrInvalidAlert)
+	case alert.Kind == AlertPriceDrop && alert.Target_Price == nil:
+		return fmt.Errorf("%w: target_price is required", ErrInvalidAlert)
+	case live && alertDue(*alert, state) && alert.Kind == AlertBackInStock:
+		return fmt.Errorf("%w: the product is already in stock", ErrInvalidAlert)
+	case live && alertDue(*alert, state):
+		return fmt.Errorf("%w: the price is already at or below target_price", ErrInvalidAlert)
+	}
+
+	now := time.Now()
+	filter := bson.M{
+		"user_id":    alert.User_Id,
+		"product_id": alert.Product_Id,
+		"sku":        alert.SKU,
+		"kind":       alert.Kind,
+		"active":     true,
+	}
+	set := bson.M{"created_at": now}
+	if alert.Target_Price != nil {
+		set["target_price"] = *alert.Target_Price
+	}
+	update := bson.M{
+		"$set":         set,
+		"$setOnInsert": bson.M{"_id": primitive.NewObjectID()},
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	return alertCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(alert)
+}
+
+func ListAlerts(ctx context.Context, alertCollection *mongo.Collection, userID string) ([]models.Alert, error) {
+	cursor, err := alertCollection.Find(ctx, bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	alerts := []models.Alert{}
+	if err = cursor.All(ctx, &alerts); err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+func DeleteAlert(ctx context.Context, alertCollection *mongo.Collection, userID string, alertID primitive.ObjectID) error {
+	result, err := alertCollection.DeleteOne(ctx, bson.M{"_id": alertID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrCantFindAlert
+	}
+	return nil
+}
+
+// DueAlerts returns the active alerts on product whose condition now holds.
+func DueAlerts(ctx context.Context, alertCollection, productCollection *mongo.Collection, product models.Product) ([]models.Alert, error) {
+	if !IsLive(product, time.Now()) {
+		return nil, nil
+	}
+
+	cursor, err := alertCollection.Find(ctx, bson.M{"product_id": product.ID, "active": true})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var alerts []models.Alert
+	if err = cursor.All(ctx, &alerts); err != nil {
+		return nil, err
+	}
+
+	states := map[string]AlertState{}
+	var due []models.Alert
+	for _, alert := range alerts {
+		key := ""
+		if alert.SKU != nil {
+			key = *alert.SKU
+		}
+		state, ok := states[key]
+		if !ok {
+			state, err = CurrentAlertState(ctx, productCollection, product, alert.SKU)
+			if errors.Is(err, ErrCantFindVariant) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			states[key] = state
+		}
+
+		if alertDue(alert, state) {
+			due = append(due, alert)
+		}
+	}
+
+	return due, nil
+}
+
+// ClaimAlert marks an alert as triggered, reporting false if another update
+// already did, so each alert is sent only once.
+func ClaimAlert(ctx context.Context, alertCollection *mongo.Collection, alertID primitive.ObjectID, at time.Time) (bool, error) {
+	result, err := alertCollection.UpdateOne(ctx,
+		bson.M{"_id": alertID, "active": true},
+		bson.M{"$set": bson.M{"active": false, "triggered_at": at}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// ReleaseAlert re-arms a claimed alert whose notification couldn't be sent.
+func ReleaseAlert(ctx context.Context, alertCollection *mongo.Collection, alertID primitive.ObjectID) error {
+	_, err := alertCollection.UpdateOne(ctx, bson.M{"_id": alertID},
+		bson.M{"$set": bson.M{"active": true}, "$unset": bson.M{"triggered_at": ""}})
+	return err
+}
+
+// BundlesContaining lists the bundles that include productID, whose
+// availability moves with its stock.
+func BundlesContaining(ctx context.Context, productCollection *mongo.Collection, productID primitive.ObjectID) ([]models.Product, error) {
+	cursor, err := productCollection.Find(ctx, bson.M{"bundle.product_id": productID})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var bundles []models.Product
+	if err = cursor.All(ctx, &bundles); err != nil {
+		return nil, ErrCantDecodeProducts
+	}
+	return bundles, nil
+}
@@ -0,0 +1,237 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+// BundleComponentDetail is a bundle component with the product it refers to.
+// Stock is nil when the component's stock isn't tracked.
+type BundleComponentDetail struct {
+	models.BundleComponent
+	Product_Name *string `json:"product_name"`
+	Image        *string `json:"image"`
+	Price        *uint32 `json:"price"`
+	Stock        *int    `json:"stock"`
+	Available    bool    `json:"available"`
+}
+
+// BundleDetail lists a bundle's components and how many whole bundles their
+// stock covers. Stock is nil when no component has tracked stock.
+type BundleDetail struct {
+	Components []BundleComponentDetail `json:"components"`
+	Stock      *int                    `json:"stock"`
+}
+
+func bundleProducts(ctx context.Context, productCollection *mongo.Collection, components []models.BundleComponent) (map[primitive.ObjectID]models.Product, error) {
+	ids := make([]primitive.ObjectID, 0, len(components))
+	for _, component := range components {
+		ids = append(ids, component.Product_Id)
+	}
+
+	cursor, err := productCollection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var products []models.Product
+	if err = cursor.All(ctx, &products); err != nil {
+		return nil, ErrCantDecodeProducts
+	}
+
+	byID := make(map[primitive.ObjectID]models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+	return byID, nil
+}
+
+// ValidateBundle checks that a bundle's components are distinct, existing,
+// non-bundle products, each naming a SKU exactly when the product has
+// variants. Bundles can't have variants of their own.
+func ValidateBundle(ctx context.Context, productCollection *mongo.Collection, product models.Product) error {
+	if len(product.Bundle) == 0 {
+		return nil
+	}
+	if len(product.Variants) > 0 {
+		return fmt.Errorf("%w: a bundle can't have variants", ErrInvalidBundle)
+	}
+
+	components, err := bundleProducts(ctx, productCollection, product.Bundle)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(product.Bundle))
+	for _, component := range product.Bundle {
+		key := component.Product_Id.Hex()
+		if component.SKU != nil {
+			key += "/" + *component.SKU
+		}
+		if seen[key] {
+			return fmt.Errorf("%w: %s is listed twice", ErrInvalidBundle, key)
+		}
+		seen[key] = true
+
+		if component.Product_Id == product.ID {
+			return fmt.Errorf("%w: a bundle can't contain itself", ErrInvalidBundle)
+		}
+		included, ok := components[component.Product_Id]
+		if !ok {
+			return fmt.Errorf("%w: %w %s", ErrInvalidBundle, ErrCantFindProduct, component.Product_Id.Hex())
+		}
+		if len(included.Bundle) > 0 {
+			return fmt.Errorf("%w: %s is itself a bundle", ErrInvalidBundle, component.Product_Id.Hex())
+		}
+
+		switch {
+		case len(included.Variants) > 0 && component.SKU == nil:
+			return fmt.Errorf("%w: %w for %s", ErrInvalidBundle, ErrSKURequired, component.Product_Id.Hex())
+		case len(included.Variants) == 0 && component.SKU != nil:
+			return fmt.Errorf("%w: %s has no variants", ErrInvalidBundle, component.Product_Id.Hex())
+		case component.SKU != nil:
+			if _, ok := FindVariant(included, *component.SKU); !ok {
+				return fmt.Errorf("%w: %w %q", ErrInvalidBundle, ErrCantFindVariant, *component.SKU)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ResolveBundle looks up a bundle's components. A component that has been
+// removed, unpublished or whose SKU is gone leaves the bundle unavailable.
+func ResolveBundle(ctx context.Context, productCollection *mongo.Collection, product models.Product) (BundleDetail, error) {
+	detail := BundleDetail{Components: []BundleComponentDetail{}}
+
+	components, err := bundleProducts(ctx, productCollection, product.Bundle)
+	if err != nil {
+		return detail, err
+	}
+
+	now := time.Now()
+	for _, component := range product.Bundle {
+		item := BundleComponentDetail{BundleComponent: component}
+
+		included, ok := components[component.Product_Id]
+		item.Available = ok && IsLive(included, now)
+		if ok {
+			item.Product_Name = included.Product_Name
+			item.Image = included.Image
+			item.Price = included.Price
+		}
+		if ok && component.SKU != nil {
+			variant, found := FindVariant(included, *component.SKU)
+			item.Available = item.Available && found
+			if found {
+				item.Price = variant.Price
+				item.Stock = variant.Stock
+			}
+		}
+
+		covers := -1
+		switch {
+		case !item.Available:
+			covers = 0
+		case item.Stock != nil:
+			covers = max(*item.Stock, 0) / component.Quantity
+		}
+		if covers >= 0 && (detail.Stock == nil || covers < *detail.Stock) {
+			detail.Stock = &covers
+		}
+
+		detail.Components = append(detail.Components, item)
+	}
+
+	return detail, nil
+}
+
+// FulfilmentItems breaks cart lines down into what has to be shipped: each
+// bundle becomes its components, and repeated lines are counted together.
+func FulfilmentItems(ctx context.Context, productCollection *mongo.Collection, items []models.ProductUser) ([]models.FulfilmentItem, error) {
+	ids := make([]primitive.ObjectID, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+
+	cursor, err := productCollection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}, "bundle.0": bson.M{"$exists": true}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var bundles []models.Product
+	if err = cursor.All(ctx, &bundles); err != nil {
+		return nil, ErrCantDecodeProducts
+	}
+
+	var components []models.BundleComponent
+	for _, bundle := range bundles {
+		components = append(components, bundle.Bundle...)
+	}
+	names := map[primitive.ObjectID]*string{}
+	if len(components) > 0 {
+		included, err := bundleProducts(ctx, productCollection, components)
+		if err != nil {
+			return nil, err
+		}
+		for id, product := range included {
+			names[id] = product.Product_Name
+		}
+	}
+
+	byID := make(map[primitive.ObjectID]models.Product, len(bundles))
+	for _, bundle := range bundles {
+		byID[bundle.ID] = bundle
+	}
+
+	var fulfilment []models.FulfilmentItem
+	add := func(item models.FulfilmentItem) {
+		for i, existing := range fulfilment {
+			if existing.Product_Id == item.Product_Id && sameString(existing.SKU, item.SKU) && sameID(existing.Bundle_Id, item.Bundle_Id) {
+				fulfilment[i].Quantity += item.Quantity
+				return
+			}
+		}
+		fulfilment = append(fulfilment, item)
+	}
+
+	for _, item := range items {
+		bundle, ok := byID[item.ID]
+		if !ok {
+			add(models.FulfilmentItem{Product_Id: item.ID, Product_Name: item.Product_Name, SKU: item.SKU, Quantity: 1})
+			continue
+		}
+
+		bundleID := bundle.ID
+		for _, component := range bundle.Bundle {
+			add(models.FulfilmentItem{
+				Product_Id:   component.Product_Id,
+				Product_Name: names[component.Product_Id],
+				SKU:          component.SKU,
+				Quantity:     component.Quantity,
+				Bundle_Id:    &bundleID,
+			})
+		}
+	}
+
+	return fulfilment, nil
+}
+
+func sameString(a, b *string) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func sameID(a, b *primitive.ObjectID) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
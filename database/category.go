@@ -0,0 +1,255 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrCantFindCategory   = errors.New("can't find the category")
+	ErrDuplicateSlug      = errors.New("slug is already in use")
+	ErrInvalidSlug        = errors.New("slug must contain a letter or digit")
+	ErrCategoryCycle      = errors.New("category can't be moved under itself or its descendants")
+	ErrInvalidCategoryIds = errors.New("one or more category IDs are invalid")
+)
+
+type CategoryNode struct {
+	models.Category `bson:",inline"`
+	Children        []*CategoryNode `json:"children"`
+}
+
+func GetCategory(ctx context.Context, categoryCollection *mongo.Collection, filter bson.M) (models.Category, error) {
+	var category models.Category
+
+	err := categoryCollection.FindOne(ctx, filter).Decode(&category)
+	if err == mongo.ErrNoDocuments {
+		return category, ErrCantFindCategory
+	}
+
+	return category, err
+}
+
+func ListCategories(ctx context.Context, categoryCollection *mongo.Collection) ([]models.Category, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "sort_order", Value: 1}, {Key: "name", Value: 1}})
+
+	cursor, err := categoryCollection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	categories := []models.Category{}
+	if err = cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+// BuildCategoryTree nests categories under their parents, keeping siblings in
+// sort order. Categories whose parent is missing are treated as roots.
+func BuildCategoryTree(categories []models.Category) []*CategoryNode {
+	nodes := make(map[primitive.ObjectID]*CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &CategoryNode{Category: category, Children: []*CategoryNode{}}
+	}
+
+	roots := []*CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.Parent_Id != nil {
+			if parent, ok := nodes[*category.Parent_Id]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	var sortNodes func([]*CategoryNode)
+	sortNodes = func(list []*CategoryNode) {
+		sort.SliceStable(list, func(i, j int) bool {
+			return list[i].Sort_Order < list[j].Sort_Order
+		})
+		for _, node := range list {
+			sortNodes(node.Children)
+		}
+	}
+	sortNodes(roots)
+
+	return roots
+}
+
+func slugTaken(ctx context.Context, categoryCollection *mongo.Collection, slug string, exclude primitive.ObjectID) (bool, error) {
+	count, err := categoryCollection.CountDocuments(ctx, bson.M{"slug": slug, "_id": bson.M{"$ne": exclude}})
+	return count > 0, err
+}
+
+func categoryAncestors(ctx context.Context, categoryCollection *mongo.Collection, parentID *primitive.ObjectID) ([]primitive.ObjectID, error) {
+	if parentID == nil {
+		return []primitive.ObjectID{}, nil
+	}
+
+	parent, err := GetCategory(ctx, categoryCollection, bson.M{"_id": *parentID})
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append([]primitive.ObjectID{}, parent.Ancestors...), parent.ID), nil
+}
+
+func CreateCategory(ctx context.Context, categoryCollection *mongo.Collection, category *models.Category) error {
+	if category.Slug == "" {
+		category.Slug = *category.Name
+	}
+	category.Slug = Slugify(category.Slug)
+	if category.Slug == "" {
+		return ErrInvalidSlug
+	}
+
+	category.ID = primitive.NewObjectID()
+
+	taken, err := slugTaken(ctx, categoryCollection, category.Slug, category.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrDuplicateSlug
+	}
+
+	category.Ancestors, err = categoryAncestors(ctx, categoryCollection, category.Parent_Id)
+	if err != nil {
+		return err
+	}
+
+	category.Created_At = time.Now()
+	category.Updated_At = time.Now()
+
+	_, err = categoryCollection.InsertOne(ctx, category)
+	return err
+}
+
+// UpdateCategory renames, reorders or re-parents a category. Moving a
+// category rewrites the ancestor path of its whole subtree.
+func UpdateCategory(ctx context.Context, categoryCollection *mongo.Collection, categoryID primitive.ObjectID, changes models.Category) (models.Category, error) {
+	category, err := GetCategory(ctx, categoryCollection, bson.M{"_id": categoryID})
+	if err != nil {
+		return category, err
+	}
+
+	if changes.Slug == "" {
+		changes.Slug = category.Slug
+	} else if changes.Slug = Slugify(changes.Slug); changes.Slug == "" {
+		return category, ErrInvalidSlug
+	}
+	if changes.Slug != category.Slug {
+		taken, err := slugTaken(ctx, categoryCollection, changes.Slug, categoryID)
+		if err != nil {
+			return category, err
+		}
+		if taken {
+			return category, ErrDuplicateSlug
+		}
+	}
+
+	ancestors, err := categoryAncestors(ctx, categoryCollection, changes.Parent_Id)
+	if err != nil {
+		return category, err
+	}
+	for _, id := range ancestors {
+		if id == categoryID {
+			return category, ErrCategoryCycle
+		}
+	}
+	if changes.Parent_Id != nil && *changes.Parent_Id == categoryID {
+		return category, ErrCategoryCycle
+	}
+
+	oldPath := append(append([]primitive.ObjectID{}, category.Ancestors...), categoryID)
+	newPath := append(append([]primitive.ObjectID{}, ancestors...), categoryID)
+
+	category.Name = changes.Name
+	category.Slug = changes.Slug
+	category.Parent_Id = changes.Parent_Id
+	category.Ancestors = ancestors
+	category.Sort_Order = changes.Sort_Order
+	category.Updated_At = time.Now()
+
+	_, err = categoryCollection.ReplaceOne(ctx, bson.M{"_id": categoryID}, category)
+	if err != nil {
+		return category, err
+	}
+
+	cursor, err := categoryCollection.Find(ctx, bson.M{"ancestors": categoryID})
+	if err != nil {
+		return category, err
+	}
+	defer cursor.Close(ctx)
+
+	var descendants []models.Category
+	if err = cursor.All(ctx, &descendants); err != nil {
+		return category, err
+	}
+
+	for _, descendant := range descendants {
+		rest := descendant.Ancestors[len(oldPath):]
+		path := append(append([]primitive.ObjectID{}, newPath...), rest...)
+		_, err = categoryCollection.UpdateOne(ctx, bson.M{"_id": descendant.ID}, bson.M{"$set": bson.M{"ancestors": path}})
+		if err != nil {
+			return category, err
+		}
+	}
+
+	return category, nil
+}
+
+// CategoryWithDescendants returns the IDs of the category and every category
+// beneath it.
+func CategoryWithDescendants(ctx context.Context, categoryCollection *mongo.Collection, categoryID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	cursor, err := categoryCollection.Find(ctx, bson.M{"ancestors": categoryID}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	ids := []primitive.ObjectID{categoryID}
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		ids = append(ids, doc.ID)
+	}
+
+	return ids, cursor.Err()
+}
+
+func SetProductCategories(ctx context.Context, productCollection, categoryCollection *mongo.Collection, productID primitive.ObjectID, categoryIDs []primitive.ObjectID) error {
+	count, err := categoryCollection.CountDocuments(ctx, bson.M{"_id": bson.M{"$in": categoryIDs}})
+	if err != nil {
+		return err
+	}
+	if int(count) != len(categoryIDs) {
+		return ErrInvalidCategoryIds
+	}
+
+	result, err := productCollection.UpdateOne(ctx, bson.M{"_id": productID}, bson.M{"$set": bson.M{"categories": categoryIDs}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrCantFindProduct
+	}
+
+	return nil
+}
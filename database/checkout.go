@@ -0,0 +1,398 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrEmptyCart              = errors.New("cart is empty")
+	ErrCheckoutNotFound       = errors.New("checkout session not found")
+	ErrCheckoutExpired        = errors.New("checkout session has expired")
+	ErrCheckoutClosed         = errors.New("checkout session is no longer open")
+	ErrCheckoutIncomplete     = errors.New("checkout session is missing address or payment method")
+	ErrInvalidShippingMethod  = errors.New("invalid shipping method")
+	ErrInvalidCoupon          = errors.New("coupon is invalid or expired")
+	ErrCouponMinimumNotMet    = errors.New("order value is below the coupon minimum")
+	ErrProductNoLongerOffered = errors.New("a product in the checkout is no longer available")
+	ErrCartChanged            = errors.New("cart has changed since checkout started")
+)
+
+const (
+	CheckoutOpen       = "open"
+	CheckoutConfirming = "confirming"
+	CheckoutConfirmed  = "confirmed"
+	CheckoutExpired    = "expired"
+)
+
+// CheckoutTTL is how long a checkout session stays open without activity.
+const CheckoutTTL = 30 * time.Minute
+
+type ShippingMethod struct {
+	Fee        uint32 `json:"fee"`
+	Free_Above uint32 `json:"free_above"`
+}
+
+var ShippingMethods = map[string]ShippingMethod{
+	"standard": {Fee: 40, Free_Above: 499},
+	"express":  {Fee: 99},
+}
+
+const DefaultShippingMethod = "standard"
+
+func CreateCheckoutSession(ctx context.Context, checkoutCollection *mongo.Collection, userCollection *mongo.Collection, userID string) (models.CheckoutSession, error) {
+	user, err := GetUser(ctx, userCollection, userID)
+	if err != nil {
+		return models.CheckoutSession{}, err
+	}
+
+	if len(user.User_Cart) == 0 {
+		return models.CheckoutSession{}, ErrEmptyCart
+	}
+
+	now := time.Now()
+	session := models.CheckoutSession{
+		ID:              primitive.NewObjectID(),
+		User_Id:         userID,
+		Status:          CheckoutOpen,
+		Items:           user.User_Cart,
+		Shipping_Method: DefaultShippingMethod,
+		Created_At:      now,
+		Updated_At:      now,
+		Expires_At:      now.Add(CheckoutTTL),
+	}
+
+	_, err = checkoutCollection.InsertOne(ctx, session)
+	return session, err
+}
+
+// GetCheckoutSession loads an open session belonging to userID, marking it
+// expired if it has been idle for longer than CheckoutTTL.
+func GetCheckoutSession(ctx context.Context, checkoutCollection *mongo.Collection, sessionID, userID string) (models.CheckoutSession, error) {
+	var session models.CheckoutSession
+
+	sessionObjID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return session, ErrCheckoutNotFound
+	}
+
+	err = checkoutCollection.FindOne(ctx, bson.M{"_id": sessionObjID, "user_id": userID}).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return session, ErrCheckoutNotFound
+	}
+	if err != nil {
+		return session, err
+	}
+
+	if session.Status == CheckoutOpen && time.Now().After(session.Expires_At) {
+		session.Status = CheckoutExpired
+		_, err = checkoutCollection.UpdateOne(ctx, bson.M{"_id": session.ID}, bson.M{"$set": bson.M{"status": CheckoutExpired}})
+		if err != nil {
+			return session, err
+		}
+	}
+
+	switch session.Status {
+	case CheckoutOpen:
+		return session, nil
+	case CheckoutExpired:
+		return session, ErrCheckoutExpired
+	default:
+		return session, ErrCheckoutClosed
+	}
+}
+
+func FindCoupon(ctx context.Context, couponCollection *mongo.Collection, code string) (models.Coupon, error) {
+	var coupon models.Coupon
+
+	err := couponCollection.FindOne(ctx, bson.M{"code": strings.ToUpper(strings.TrimSpace(code)), "active": true}).Decode(&coupon)
+	if err == mongo.ErrNoDocuments {
+		return coupon, ErrInvalidCoupon
+	}
+	if err != nil {
+		return coupon, err
+	}
+
+	if coupon.Expires_At != nil && time.Now().After(*coupon.Expires_At) {
+		return coupon, ErrInvalidCoupon
+	}
+
+	return coupon, nil
+}
+
+// PriceCheckoutSession refreshes item prices from the catalogue and
+// recomputes shipping, coupon discount and total.
+func PriceCheckoutSession(ctx context.Context, productCollection, couponCollection *mongo.Collection, session *models.CheckoutSession) error {
+	ids := make([]primitive.ObjectID, 0, len(session.Items))
+	for _, item := range session.Items {
+		ids = append(ids, item.ID)
+	}
+
+	cursor, err := productCollection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var products []models.Product
+	if err = cursor.All(ctx, &products); err != nil {
+		return ErrCantDecodeProducts
+	}
+
+	current := make(map[primitive.ObjectID]models.Product, len(products))
+	for _, product := range products {
+		current[product.ID] = product
+	}
+
+	now := time.Now()
+	var subtotal uint32
+	for i, item := range session.Items {
+		product, ok := current[item.ID]
+		if !ok || !IsLive(product, now) {
+			return ErrProductNoLongerOffered
+		}
+
+		if len(product.Bundle) > 0 {
+			bundle, err := ResolveBundle(ctx, productCollection, product)
+			if err != nil {
+				return err
+			}
+			if bundle.Stock != nil && *bundle.Stock == 0 {
+				return ErrProductNoLongerOffered
+			}
+		}
+
+		price := product.Price
+		if item.SKU != nil {
+			variant, ok := FindVariant(product, *item.SKU)
+			if !ok {
+				return ErrProductNoLongerOffered
+			}
+			price = variant.Price
+		}
+
+		session.Items[i].Price = price
+		if price != nil {
+			subtotal += *price
+		}
+	}
+
+	method, ok := ShippingMethods[session.Shipping_Method]
+	if !ok {
+		return ErrInvalidShippingMethod
+	}
+
+	session.Subtotal = subtotal
+	session.Shipping_Fee = method.Fee
+	if method.Free_Above > 0 && subtotal >= method.Free_Above {
+		session.Shipping_Fee = 0
+	}
+
+	session.Discount = 0
+	session.Discount_Amount = 0
+	if session.Coupon_Code != nil {
+		coupon, err := FindCoupon(ctx, couponCollection, *session.Coupon_Code)
+		if err != nil {
+			return err
+		}
+		if coupon.Min_Order_Value != nil && subtotal < *coupon.Min_Order_Value {
+			return ErrCouponMinimumNotMet
+		}
+		session.Discount = *coupon.Percent_Off
+		session.Discount_Amount = uint32(uint64(subtotal) * uint64(*coupon.Percent_Off) / 100)
+	}
+
+	session.Total = subtotal - session.Discount_Amount + session.Shipping_Fee
+	return nil
+}
+
+// SaveCheckoutSession persists the session and pushes its expiry out, since
+// every step counts as activity. It fails with ErrCheckoutClosed if the
+// session was claimed or confirmed since it was loaded.
+func SaveCheckoutSession(ctx context.Context, checkoutCollection *mongo.Collection, session *models.CheckoutSession) error {
+	return replaceCheckoutSession(ctx, checkoutCollection, session, CheckoutOpen)
+}
+
+// replaceCheckoutSession writes session over the stored one, provided the
+// stored one is still in status.
+func replaceCheckoutSession(ctx context.Context, checkoutCollection *mongo.Collection, session *models.CheckoutSession, status string) error {
+	now := time.Now()
+	session.Updated_At = now
+	session.Expires_At = now.Add(CheckoutTTL)
+
+	result, err := checkoutCollection.ReplaceOne(ctx, bson.M{"_id": session.ID, "status": status}, session)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrCheckoutClosed
+	}
+	return nil
+}
+
+// ClaimCheckoutSession moves an open session to confirming so only one
+// confirm request can place its order. It returns ErrCheckoutClosed if
+// another request got there first.
+func ClaimCheckoutSession(ctx context.Context, checkoutCollection *mongo.Collection, sessionID primitive.ObjectID) error {
+	result, err := checkoutCollection.UpdateOne(ctx,
+		bson.M{"_id": sessionID, "status": CheckoutOpen},
+		bson.M{"$set": bson.M{"status": CheckoutConfirming}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrCheckoutClosed
+	}
+	return nil
+}
+
+// ReopenCheckoutSession hands a claimed session back when its confirm fails.
+func ReopenCheckoutSession(ctx context.Context, checkoutCollection *mongo.Collection, sessionID primitive.ObjectID) error {
+	_, err := checkoutCollection.UpdateOne(ctx,
+		bson.M{"_id": sessionID, "status": CheckoutConfirming},
+		bson.M{"$set": bson.M{"status": CheckoutOpen}})
+	return err
+}
+
+// cartMatchesSnapshot is an $expr that holds only while the user's cart has
+// exactly the lines, unit for unit, that the session was started with, so
+// confirming can't drop lines added since or buy lines that were moved out.
+func cartMatchesSnapshot(items []models.ProductUser) bson.M {
+	type line struct {
+		id  primitive.ObjectID
+		sku string
+	}
+	var order []line
+	counts := map[line]int{}
+	skus := map[line]*string{}
+	for _, item := range items {
+		key := line{id: item.ID}
+		if item.SKU != nil {
+			key.sku = *item.SKU
+		}
+		if counts[key] == 0 {
+			order = append(order, key)
+			skus[key] = item.SKU
+		}
+		counts[key]++
+	}
+
+	conditions := bson.A{
+		bson.M{"$eq": bson.A{bson.M{"$size": bson.M{"$ifNull": bson.A{"$user_cart", bson.A{}}}}, len(items)}},
+	}
+	for _, key := range order {
+		matching := bson.M{"$filter": bson.M{
+			"input": bson.M{"$ifNull": bson.A{"$user_cart", bson.A{}}},
+			"cond":  cartLineExpr(key.id, skus[key]),
+		}}
+		conditions = append(conditions, bson.M{"$eq": bson.A{bson.M{"$size": matching}, counts[key]}})
+	}
+	return bson.M{"$and": conditions}
+}
+
+// ConfirmCheckoutSession turns the session into an Order on the user, empties
+// their cart and closes the session. It fails with ErrCartChanged if the cart
+// no longer matches the session. The addresses are copied onto the order
+// so later edits to the user's Address_Details don't rewrite it.
+func ConfirmCheckoutSession(ctx context.Context, checkoutCollection, userCollection *mongo.Collection, session *models.CheckoutSession, shipping models.Address, billing *models.Address, fulfilment []models.FulfilmentItem) (models.Order, error) {
+	if session.Address_Id == nil || session.Payment_Method == nil {
+		return models.Order{}, ErrCheckoutIncomplete
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(session.User_Id)
+	if err != nil {
+		return models.Order{}, ErrUserIdIsNotValid
+	}
+
+	total := session.Total
+	order := models.Order{
+		ID:               primitive.NewObjectID(),
+		Order_Cart:       session.Items,
+		Ordered_At:       time.Now(),
+		Price:            &total,
+		Payment_Method:   *session.Payment_Method,
+		Shipping_Address: &shipping,
+		Billing_Address:  billing,
+		Fulfilment_Items: fulfilment,
+	}
+	if session.Discount > 0 {
+		discount := session.Discount
+		order.Discount = &discount
+	}
+
+	if err := placeCartOrder(ctx, userCollection, userObjID, order); err != nil {
+		return models.Order{}, err
+	}
+
+	session.Status = CheckoutConfirmed
+	session.Order_Id = &order.ID
+	if err := replaceCheckoutSession(ctx, checkoutCollection, session, CheckoutConfirming); err != nil {
+		return order, err
+	}
+
+	return order, nil
+}
+
+// PlaceCartOrder turns the user's cart into an Order and empties it, without
+// a checkout session. cart is the cart the caller checked; it fails with
+// ErrCartChanged if the stored cart no longer matches it. The addresses are
+// copied onto the order as ConfirmCheckoutSession does.
+func PlaceCartOrder(ctx context.Context, userCollection *mongo.Collection, userID string, cart []models.ProductUser, payment models.Payment, shipping models.Address, billing *models.Address, fulfilment []models.FulfilmentItem) (models.Order, error) {
+	if len(cart) == 0 {
+		return models.Order{}, ErrEmptyCart
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return models.Order{}, ErrUserIdIsNotValid
+	}
+
+	var total uint32
+	for _, item := range cart {
+		if item.Price != nil {
+			total += *item.Price
+		}
+	}
+
+	order := models.Order{
+		ID:               primitive.NewObjectID(),
+		Order_Cart:       cart,
+		Ordered_At:       time.Now(),
+		Price:            &total,
+		Payment_Method:   payment,
+		Shipping_Address: &shipping,
+		Billing_Address:  billing,
+		Fulfilment_Items: fulfilment,
+	}
+
+	if err := placeCartOrder(ctx, userCollection, userObjID, order); err != nil {
+		return models.Order{}, err
+	}
+	return order, nil
+}
+
+// placeCartOrder pushes order onto the user and empties their cart, provided
+// the cart still holds exactly the order's lines.
+func placeCartOrder(ctx context.Context, userCollection *mongo.Collection, userObjID primitive.ObjectID, order models.Order) error {
+	update := bson.M{
+		"$push": bson.M{"order_status": order},
+		"$set":  bson.M{"user_cart": []models.ProductUser{}},
+	}
+
+	filter := bson.M{"_id": userObjID, "$expr": cartMatchesSnapshot(order.Order_Cart)}
+	result, err := userCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return ErrCantBuyCartItem
+	}
+	if result.MatchedCount == 0 {
+		return ErrCartChanged
+	}
+	return nil
+}
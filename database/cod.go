@@ -0,0 +1,131 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrCODNotEligible = errors.New("order is not eligible for cash on delivery")
+
+// codRulesKey is the key of the single COD rules document.
+const codRulesKey = "cod_rules"
+
+func GetCODRules(ctx context.Context, codRulesCollection *mongo.Collection) (models.CODRules, error) {
+	rules := models.CODRules{Enabled: true}
+
+	err := codRulesCollection.FindOne(ctx, bson.M{"key": codRulesKey}).Decode(&rules)
+	if err == mongo.ErrNoDocuments {
+		return rules, nil
+	}
+
+	return rules, err
+}
+
+func SaveCODRules(ctx context.Context, codRulesCollection *mongo.Collection, rules models.CODRules) error {
+	update := bson.M{
+		"$set": bson.M{
+			"enabled":                rules.Enabled,
+			"max_order_value":        rules.Max_Order_Value,
+			"allowed_pincodes":       rules.Allowed_Pincodes,
+			"max_refused_deliveries": rules.Max_Refused_Deliveries,
+			"excluded_products":      rules.Excluded_Products,
+			"updated_at":             time.Now(),
+		},
+	}
+
+	_, err := codRulesCollection.UpdateOne(ctx, bson.M{"key": codRulesKey}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// EvaluateCOD reports whether an order of items shipped to pincode may be
+// paid by cash on delivery, and the reason when it may not.
+func EvaluateCOD(rules models.CODRules, user models.User, pincode string, items []models.ProductUser) (bool, string) {
+	if !rules.Enabled {
+		return false, "cash on delivery is currently disabled"
+	}
+
+	if len(rules.Allowed_Pincodes) > 0 {
+		allowed := false
+		for _, p := range rules.Allowed_Pincodes {
+			if p == pincode {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false, fmt.Sprintf("cash on delivery is not offered for pincode %s", pincode)
+		}
+	}
+
+	if rules.Max_Order_Value != nil {
+		var total uint32
+		for _, item := range items {
+			if item.Price != nil {
+				total += *item.Price
+			}
+		}
+		if total > *rules.Max_Order_Value {
+			return false, fmt.Sprintf("order value exceeds the cash on delivery limit of %d", *rules.Max_Order_Value)
+		}
+	}
+
+	if rules.Max_Refused_Deliveries != nil && user.COD_Refusals > *rules.Max_Refused_Deliveries {
+		return false, "cash on delivery is unavailable due to previously refused deliveries"
+	}
+
+	excluded := make(map[primitive.ObjectID]bool, len(rules.Excluded_Products))
+	for _, id := range rules.Excluded_Products {
+		excluded[id] = true
+	}
+	for _, item := range items {
+		if excluded[item.ID] {
+			name := item.ID.Hex()
+			if item.Product_Name != nil {
+				name = *item.Product_Name
+			}
+			return false, fmt.Sprintf("%s is not eligible for cash on delivery", name)
+		}
+	}
+
+	return true, ""
+}
+
+// CheckCODEligibility evaluates the stored COD rules, returning
+// ErrCODNotEligible along with the reason when COD is denied.
+func CheckCODEligibility(ctx context.Context, codRulesCollection *mongo.Collection, user models.User, pincode string, items []models.ProductUser) (string, error) {
+	rules, err := GetCODRules(ctx, codRulesCollection)
+	if err != nil {
+		return "", err
+	}
+
+	if ok, reason := EvaluateCOD(rules, user, pincode, items); !ok {
+		return reason, ErrCODNotEligible
+	}
+
+	return "", nil
+}
+
+func RecordCODRefusal(ctx context.Context, userCollection *mongo.Collection, userID string) error {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return ErrUserIdIsNotValid
+	}
+
+	result, err := userCollection.UpdateOne(ctx, bson.M{"_id": userObjID}, bson.M{"$inc": bson.M{"cod_refusals": 1}})
+	if err != nil {
+		return ErrCantUpdateUser
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserIdIsNotValid
+	}
+
+	return nil
+}
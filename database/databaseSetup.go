@@ -48,3 +48,8 @@ func ProductData(client *mongo.Client, collectionName string) *mongo.Collection
 	var productCollection *mongo.Collection = client.Database("Ecommerce_Cart").Collection(collectionName)
 	return productCollection
 }
+
+func CollectionData(client *mongo.Client, collectionName string) *mongo.Collection {
+	var collection *mongo.Collection = client.Database("Ecommerce_Cart").Collection(collectionName)
+	return collection
+}
@@ -0,0 +1,260 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type FacetCount struct {
+	Value string `json:"value" bson:"value"`
+	Count int    `json:"count" bson:"count"`
+}
+
+type ProductFilter struct {
+	Attributes map[string][]string
+	Min_Price  *uint32
+	Max_Price  *uint32
+	Min_Rating *uint8
+	Max_Rating *uint8
+	// Categories, when set, limits the results to products in any of them.
+	Categories []primitive.ObjectID
+	// Live_At, when set, limits the results to products live at that time.
+	Live_At time.Time
+}
+
+// Match turns the filter into a query. Values for the same attribute are
+// OR'd together; different attributes and ranges are AND'd.
+func (f ProductFilter) Match() bson.M {
+	match := bson.M{}
+	if !f.Live_At.IsZero() {
+		match = LiveProductFilter(f.Live_At)
+	}
+
+	if len(f.Categories) > 0 {
+		match["categories"] = bson.M{"$in": f.Categories}
+	}
+
+	for name, values := range f.Attributes {
+		if len(values) > 0 {
+			match["attributes."+name] = bson.M{"$in": values}
+		}
+	}
+
+	price := bson.M{}
+	if f.Min_Price != nil {
+		price["$gte"] = *f.Min_Price
+	}
+	if f.Max_Price != nil {
+		price["$lte"] = *f.Max_Price
+	}
+	if len(price) > 0 {
+		match["price"] = price
+	}
+
+	rating := bson.M{}
+	if f.Min_Rating != nil {
+		rating["$gte"] = *f.Min_Rating
+	}
+	if f.Max_Rating != nil {
+		rating["$lte"] = *f.Max_Rating
+	}
+	if len(rating) > 0 {
+		match["rating_average"] = rating
+	}
+
+	return match
+}
+
+// without returns the filter with no constraint on attribute name.
+func (f ProductFilter) without(name string) ProductFilter {
+	attributes := make(map[string][]string, len(f.Attributes))
+	for other, values := range f.Attributes {
+		if other != name {
+			attributes[other] = values
+		}
+	}
+	f.Attributes = attributes
+	return f
+}
+
+type ProductPage struct {
+	Sort ProductSort
+	// After is the cursor of the previous page's last row; nil for the first
+	// page.
+	After  *ProductCursor
+	Limit  int64
+	Fields []string
+}
+
+type ProductResults struct {
+	Products    []bson.M
+	Total       int64
+	Facets      map[string][]FacetCount
+	Next_Cursor string
+}
+
+// FacetedProductSearch returns one page of the products matching filter, the
+// total number of matches, per-attribute value counts, and the cursor for the
+// next page when there is one.
+func FacetedProductSearch(ctx context.Context, productCollection *mongo.Collection, filter ProductFilter, page ProductPage) (ProductResults, error) {
+	results := ProductResults{Products: []bson.M{}, Facets: map[string][]FacetCount{}}
+	match := filter.Match()
+
+	query := match
+	if page.After != nil {
+		query = bson.M{"$and": bson.A{match, page.Sort.After(*page.After)}}
+	}
+
+	// One row past the page tells whether there is a next page. The sort
+	// field is always read for the cursor, and dropped again if it wasn't
+	// asked for.
+	opts := options.Find().SetSort(page.Sort.Sort()).SetLimit(page.Limit + 1)
+	dropSortField := false
+	if len(page.Fields) > 0 {
+		projection := bson.M{"_id": 1}
+		for _, field := range page.Fields {
+			projection[field] = 1
+		}
+		if _, ok := projection[page.Sort.Field]; !ok {
+			projection[page.Sort.Field] = 1
+			dropSortField = true
+		}
+		opts.SetProjection(projection)
+	} else {
+		opts.SetProjection(bson.M{"search_text": 0})
+	}
+
+	cursor, err := productCollection.Find(ctx, query, opts)
+	if err != nil {
+		return results, err
+	}
+	defer cursor.Close(ctx)
+
+	if err = cursor.All(ctx, &results.Products); err != nil {
+		return results, ErrCantDecodeProducts
+	}
+
+	if int64(len(results.Products)) > page.Limit {
+		results.Products = results.Products[:page.Limit]
+		last := results.Products[len(results.Products)-1]
+		lastID, _ := last["_id"].(primitive.ObjectID)
+		results.Next_Cursor = EncodeProductCursor(ProductCursor{
+			Field: page.Sort.Field,
+			Order: page.Sort.Order,
+			Value: last[page.Sort.Field],
+			ID:    lastID,
+		})
+	}
+	if dropSortField {
+		for _, product := range results.Products {
+			delete(product, page.Sort.Field)
+		}
+	}
+
+	// Facets are disjunctive: an attribute that is being filtered on is
+	// counted with every filter but its own, so its other values stay
+	// visible. The other attributes are counted over all matches.
+	selected := []string{}
+	for name, values := range filter.Attributes {
+		if len(values) > 0 {
+			selected = append(selected, name)
+		}
+	}
+
+	facets := bson.M{
+		"total": bson.A{bson.M{"$match": match}, bson.M{"$count": "count"}},
+		"attributes": bson.A{
+			bson.M{"$match": match},
+			bson.M{"$project": bson.M{"attribute": bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{"$attributes", bson.M{}}}}}},
+			bson.M{"$unwind": "$attribute"},
+			bson.M{"$match": bson.M{"attribute.k": bson.M{"$nin": selected}}},
+			bson.M{"$group": bson.M{
+				"_id":   bson.M{"name": "$attribute.k", "value": "$attribute.v"},
+				"count": bson.M{"$sum": 1},
+			}},
+		},
+	}
+	for i, name := range selected {
+		facets["selected_"+strconv.Itoa(i)] = bson.A{
+			bson.M{"$match": filter.without(name).Match()},
+			bson.M{"$match": bson.M{"attributes." + name: bson.M{"$exists": true}}},
+			bson.M{"$group": bson.M{
+				"_id":   bson.M{"name": name, "value": "$attributes." + name},
+				"count": bson.M{"$sum": 1},
+			}},
+		}
+	}
+
+	// The outer match is what every facet has in common: the filter without
+	// any attribute constraints.
+	common := filter
+	common.Attributes = nil
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: common.Match()}},
+		{{Key: "$facet", Value: facets}},
+	}
+
+	aggregate, err := productCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return results, err
+	}
+	defer aggregate.Close(ctx)
+
+	type facetCounts []struct {
+		ID struct {
+			Name  string `bson:"name"`
+			Value string `bson:"value"`
+		} `bson:"_id"`
+		Count int `bson:"count"`
+	}
+	var out []map[string]bson.RawValue
+	if err = aggregate.All(ctx, &out); err != nil {
+		return results, ErrCantDecodeProducts
+	}
+	if len(out) == 0 {
+		return results, nil
+	}
+
+	var total []struct {
+		Count int64 `bson:"count"`
+	}
+	if err := out[0]["total"].Unmarshal(&total); err != nil {
+		return results, ErrCantDecodeProducts
+	}
+	if len(total) > 0 {
+		results.Total = total[0].Count
+	}
+
+	for key, raw := range out[0] {
+		if key == "total" {
+			continue
+		}
+		var counts facetCounts
+		if err := raw.Unmarshal(&counts); err != nil {
+			return results, ErrCantDecodeProducts
+		}
+		for _, count := range counts {
+			results.Facets[count.ID.Name] = append(results.Facets[count.ID.Name], FacetCount{
+				Value: count.ID.Value,
+				Count: count.Count,
+			})
+		}
+	}
+	for _, counts := range results.Facets {
+		sort.Slice(counts, func(i, j int) bool {
+			if counts[i].Count != counts[j].Count {
+				return counts[i].Count > counts[j].Count
+			}
+			return counts[i].Value < counts[j].Value
+		})
+	}
+
+	return results, nil
+}
@@ -0,0 +1,226 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrCantFindVersion = errors.New("can't find that version of the product")
+
+const (
+	ProductBaseline   = "baseline"
+	ProductCreated    = "create"
+	ProductUpdated    = "update"
+	ProductRecategory = "categories"
+	ProductRestatus   = "status"
+	ProductReverted   = "revert"
+	ProductReimaged   = "images"
+	ProductDeleted    = "delete"
+)
+
+// revertableFields are restored by RevertProduct. Ratings are computed from
+// reviews and stay as they are.
+var revertableFields = []string{
+	"product_name", "sku", "slug", "previous_slugs", "description", "specifications",
+	"seo_title", "seo_description", "price", "image", "options", "variants", "bundle",
+	"categories", "attributes", "search_text", "status", "publish_at", "unpublish_at",
+}
+
+// DiffProducts lists the top-level fields that differ between before and
+// after, keyed by bson name. Either side may be nil.
+func DiffProducts(before, after *models.Product) map[string]models.FieldChange {
+	if before == nil {
+		before = &models.Product{}
+	}
+	if after == nil {
+		after = &models.Product{}
+	}
+
+	changes := map[string]models.FieldChange{}
+	b, a := reflect.ValueOf(*before), reflect.ValueOf(*after)
+	t := b.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("bson"), ",")[0]
+		if name == "_id" || t.Field(i).Tag.Get("json") == "-" {
+			continue
+		}
+
+		from, to := b.Field(i).Interface(), a.Field(i).Interface()
+		if sameValue(from, to) {
+			continue
+		}
+		changes[name] = models.FieldChange{From: from, To: to}
+	}
+
+	return changes
+}
+
+// sameValue compares field values, treating times equal by instant rather
+// than by location.
+func sameValue(a, b interface{}) bool {
+	if ta, ok := a.(*time.Time); ok {
+		tb := b.(*time.Time)
+		if ta == nil || tb == nil {
+			return ta == tb
+		}
+		return ta.Equal(*tb)
+	}
+
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	if (av.Kind() == reflect.Slice || av.Kind() == reflect.Map) && av.Len() == 0 && bv.Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func EnsureProductHistoryIndexes(ctx context.Context, historyCollection *mongo.Collection) error {
+	_, err := historyCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "product_id", Value: 1}, {Key: "version", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// versionRetries bounds how often a version number lost to a concurrent
+// edit is retried.
+const versionRetries = 5
+
+// RecordProductVersion appends a version to a product's history. after is
+// nil for deletions; edits that changed nothing aren't recorded. A product
+// with no history yet first gets a baseline version of its state before the
+// change, so that state can be reverted to.
+func RecordProductVersion(ctx context.Context, historyCollection *mongo.Collection, action, changedBy string, before, after *models.Product, revertedTo *int) error {
+	changes := DiffProducts(before, after)
+	if len(changes) == 0 && action != ProductDeleted {
+		return nil
+	}
+
+	snapshot := after
+	if snapshot == nil {
+		snapshot = before
+	}
+
+	if before != nil && action != ProductCreated {
+		count, err := historyCollection.CountDocuments(ctx, bson.M{"product_id": before.ID})
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			// The baseline is always version 1; a concurrent edit that
+			// already wrote it leaves a duplicate key, which is fine.
+			baseline := models.ProductVersion{
+				ID:         primitive.NewObjectID(),
+				Product_Id: before.ID,
+				Version:    1,
+				Action:     ProductBaseline,
+				Changed_At: time.Now(),
+				Changes:    map[string]models.FieldChange{},
+				Snapshot:   *before,
+			}
+			_, err := historyCollection.InsertOne(ctx, baseline)
+			if err != nil && !mongo.IsDuplicateKeyError(err) {
+				return err
+			}
+		}
+	}
+
+	version := models.ProductVersion{
+		Product_Id:  snapshot.ID,
+		Action:      action,
+		Changed_By:  changedBy,
+		Changed_At:  time.Now(),
+		Changes:     changes,
+		Reverted_To: revertedTo,
+		Snapshot:    *snapshot,
+	}
+	return insertProductVersion(ctx, historyCollection, &version)
+}
+
+// insertProductVersion numbers version after the product's latest one. The
+// unique index on product_id and version turns a concurrent edit taking the
+// same number into a duplicate key error, and the number is read again.
+func insertProductVersion(ctx context.Context, historyCollection *mongo.Collection, version *models.ProductVersion) error {
+	var err error
+	for attempt := 0; attempt < versionRetries; attempt++ {
+		var last models.ProductVersion
+		err = historyCollection.FindOne(ctx, bson.M{"product_id": version.Product_Id},
+			options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})).Decode(&last)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+
+		version.ID = primitive.NewObjectID()
+		version.Version = last.Version + 1
+		_, err = historyCollection.InsertOne(ctx, version)
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// ListProductVersions returns a page of a product's history, newest first.
+func ListProductVersions(ctx context.Context, historyCollection *mongo.Collection, productID primitive.ObjectID, skip, limit int64) ([]models.ProductVersion, int64, error) {
+	filter := bson.M{"product_id": productID}
+
+	total, err := historyCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}}).SetSkip(skip).SetLimit(limit)
+	cursor, err := historyCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	versions := []models.ProductVersion{}
+	if err = cursor.All(ctx, &versions); err != nil {
+		return nil, 0, err
+	}
+
+	return versions, total, nil
+}
+
+func GetProductVersion(ctx context.Context, historyCollection *mongo.Collection, productID primitive.ObjectID, version int) (models.ProductVersion, error) {
+	var found models.ProductVersion
+	err := historyCollection.FindOne(ctx, bson.M{"product_id": productID, "version": version}).Decode(&found)
+	if err == mongo.ErrNoDocuments {
+		return found, ErrCantFindVersion
+	}
+	return found, err
+}
+
+// RevertProduct writes the revertable fields of target over the stored
+// product and returns the result.
+func RevertProduct(ctx context.Context, productCollection *mongo.Collection, target models.Product) (models.Product, error) {
+	set := bson.M{}
+	v := reflect.ValueOf(target)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("bson"), ",")[0]
+		for _, field := range revertableFields {
+			if field == name {
+				set[name] = v.Field(i).Interface()
+			}
+		}
+	}
+
+	var updated models.Product
+	err := productCollection.FindOneAndUpdate(ctx, bson.M{"_id": target.ID}, bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return updated, ErrCantFindProduct
+	}
+	return updated, err
+}
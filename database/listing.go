@@ -0,0 +1,173 @@
+package database
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidSort   = errors.New("invalid sort option")
+	ErrInvalidField  = errors.New("invalid field selection")
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ProductSort orders a product listing by one field. Every order but newest
+// ends on _id ascending so rows with equal values keep a stable order.
+type ProductSort struct {
+	Field string
+	Order int
+}
+
+// ProductSorts maps the sort query values to their sort order.
+var ProductSorts = map[string]ProductSort{
+	"newest":  {Field: "_id", Order: -1},
+	"price":   {Field: "price", Order: 1},
+	"-price":  {Field: "price", Order: -1},
+	"rating":  {Field: "rating_average", Order: 1},
+	"-rating": {Field: "rating_average", Order: -1},
+	"name":    {Field: "product_name", Order: 1},
+	"-name":   {Field: "product_name", Order: -1},
+}
+
+func (s ProductSort) Sort() bson.D {
+	if s.Field == "_id" {
+		return bson.D{{Key: "_id", Value: s.Order}}
+	}
+	return bson.D{{Key: s.Field, Value: s.Order}, {Key: "_id", Value: 1}}
+}
+
+// After matches the rows that sort after the cursor's row. Missing and null
+// values sort lowest, so they come first ascending and last descending.
+func (s ProductSort) After(cursor ProductCursor) bson.M {
+	if s.Field == "_id" {
+		op := "$gt"
+		if s.Order < 0 {
+			op = "$lt"
+		}
+		return bson.M{"_id": bson.M{op: cursor.ID}}
+	}
+
+	tie := bson.M{s.Field: cursor.Value, "_id": bson.M{"$gt": cursor.ID}}
+	switch {
+	case cursor.Value == nil && s.Order > 0:
+		return bson.M{"$or": bson.A{bson.M{s.Field: bson.M{"$ne": nil}}, tie}}
+	case cursor.Value == nil:
+		return tie
+	case s.Order > 0:
+		return bson.M{"$or": bson.A{bson.M{s.Field: bson.M{"$gt": cursor.Value}}, tie}}
+	default:
+		return bson.M{"$or": bson.A{bson.M{s.Field: bson.M{"$lt": cursor.Value}}, tie, bson.M{s.Field: nil}}}
+	}
+}
+
+const DefaultProductSort = "newest"
+
+// productFields holds the selectable top-level fields, taken from the bson
+// tags on models.Product. Fields hidden from JSON aren't selectable.
+var productFields = func() map[string]bool {
+	fields := map[string]bool{}
+	t := reflect.TypeOf(models.Product{})
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("json") == "-" {
+			continue
+		}
+		name := strings.Split(t.Field(i).Tag.Get("bson"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}()
+
+func ParseProductFields(raw string) ([]string, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var fields []string
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if !productFields[field] {
+			return nil, ErrInvalidField
+		}
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// EncodeCursor and DecodeCursor keep the page offset opaque to clients.
+func EncodeCursor(offset int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(offset, 10)))
+}
+
+func DecodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	offset, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidCursor
+	}
+
+	return offset, nil
+}
+
+// ProductCursor marks the last row of a product listing page by its sort
+// value and _id, so the next page starts after it however the listing has
+// changed in between. It records the sort it was made for.
+type ProductCursor struct {
+	Field string             `bson:"f"`
+	Order int                `bson:"o"`
+	Value interface{}        `bson:"v"`
+	ID    primitive.ObjectID `bson:"id"`
+}
+
+func EncodeProductCursor(cursor ProductCursor) string {
+	raw, err := bson.Marshal(cursor)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeProductCursor reads a cursor made for sort. An empty cursor is the
+// first page and returns nil.
+func DecodeProductCursor(raw string, sort ProductSort) (*ProductCursor, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor ProductCursor
+	if err := bson.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Field != sort.Field || cursor.Order != sort.Order || cursor.ID.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ProductOrderCounts counts, for every product, the orders across all users
+// that contained it.
+func ProductOrderCounts(ctx context.Context, userCollection *mongo.Collection) (map[primitive.ObjectID]int64, error) {
+	return countProducts(ctx, userCollection, mongo.Pipeline{
+		{{Key: "$unwind", Value: "$order_status"}},
+		{{Key: "$project", Value: bson.M{"items": bson.M{"$setUnion": bson.A{"$order_status.order_cart._id", bson.A{}}}}}},
+		{{Key: "$unwind", Value: "$items"}},
+		{{Key: "$group", Value: bson.M{"_id": "$items", "count": bson.M{"$sum": 1}}}},
+	})
+}
+
+// ProductViewCounts counts, for every product, the users who viewed it
+// recently.
+func ProductViewCounts(ctx context.Context, recentlyViewedCollection *mongo.Collection) (map[primitive.ObjectID]int64, error) {
+	return countProducts(ctx, recentlyViewedCollection, mongo.Pipeline{
+		{{Key: "$unwind", Value: "$items"}},
+		{{Key: "$group", Value: bson.M{"_id": "$items.product_id", "count": bson.M{"$sum": 1}}}},
+	})
+}
+
+func countProducts(ctx context.Context, collection *mongo.Collection, pipeline mongo.Pipeline) (map[primitive.ObjectID]int64, error) {
+	cursor, err := collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	counts := map[primitive.ObjectID]int64{}
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID    primitive.ObjectID `bson:"_id"`
+			Count int64              `bson:"count"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		counts[doc.ID] = doc.Count
+	}
+
+	return counts, cursor.Err()
+}
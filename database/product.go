@@ -0,0 +1,82 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func GetProduct(ctx context.Context, productCollection *mongo.Collection, productID primitive.ObjectID) (models.Product, error) {
+	var product models.Product
+	err := productCollection.FindOne(ctx, bson.M{"_id": productID}).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		return product, ErrCantFindProduct
+	}
+	return product, err
+}
+
+// ValidateProduct checks a product against the model's rules, its variants,
+// SKU uniqueness, its publish window and any bundle components, and fills in
+// the derived fields, including a unique slug when none was chosen.
+func ValidateProduct(ctx context.Context, productCollection *mongo.Collection, product *models.Product) error {
+	validate := validator.New()
+	if err := validate.Struct(product); err != nil {
+		return err
+	}
+
+	if err := ValidateVariants(*product); err != nil {
+		return err
+	}
+
+	skus := make([]string, 0, len(product.Variants)+1)
+	if product.SKU != nil {
+		if _, ok := FindVariant(*product, *product.SKU); ok {
+			return ErrDuplicateSKU
+		}
+		skus = append(skus, *product.SKU)
+	}
+	for _, variant := range product.Variants {
+		skus = append(skus, variant.SKU)
+	}
+
+	inUse, err := SKUsInUse(ctx, productCollection, skus, product.ID)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return ErrDuplicateSKU
+	}
+
+	if err := ValidatePublishing(product); err != nil {
+		return err
+	}
+
+	if err := ValidateBundle(ctx, productCollection, *product); err != nil {
+		return err
+	}
+
+	if err := assignProductSlug(ctx, productCollection, product); err != nil {
+		return err
+	}
+
+	product.Search_Text = SearchText(*product)
+	return nil
+}
+
+// IsInvalidProduct reports whether err from ValidateProduct is a problem with
+// the product itself rather than with the database.
+func IsInvalidProduct(err error) bool {
+	var validationErrs validator.ValidationErrors
+	return errors.As(err, &validationErrs) ||
+		errors.Is(err, ErrInvalidVariants) ||
+		errors.Is(err, ErrDuplicateSKU) ||
+		errors.Is(err, ErrDuplicateSlug) ||
+		errors.Is(err, ErrInvalidSlug) ||
+		errors.Is(err, ErrInvalidPublishWindow) ||
+		errors.Is(err, ErrInvalidBundle)
+}
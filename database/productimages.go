@@ -0,0 +1,154 @@
+package database
+
+import (
+	"context"
+	"errors"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrCantFindImage     = errors.New("can't find the product image")
+	ErrTooManyImages     = errors.New("product already has the maximum number of images")
+	ErrInvalidImageOrder = errors.New("image_ids must list each of the product's images exactly once")
+)
+
+const MaxProductImages = 20
+
+// SyncPrimaryImage points Image at the first uploaded image, so everything
+// that reads the single image (cart lines, orders) shows the primary one.
+func SyncPrimaryImage(product *models.Product) {
+	if len(product.Images) > 0 {
+		url := product.Images[0].URL
+		product.Image = &url
+	}
+}
+
+// savePrimaryImage stores the result of SyncPrimaryImage for product. When
+// the last uploaded image has gone the image is cleared.
+func savePrimaryImage(ctx context.Context, productCollection *mongo.Collection, product *models.Product) error {
+	update := bson.M{"$unset": bson.M{"image": ""}}
+	if len(product.Images) > 0 {
+		SyncPrimaryImage(product)
+		update = bson.M{"$set": bson.M{"image": product.Image}}
+	} else {
+		product.Image = nil
+	}
+
+	_, err := productCollection.UpdateOne(ctx, bson.M{"_id": product.ID}, update)
+	return err
+}
+
+func AddProductImage(ctx context.Context, productCollection *mongo.Collection, productID primitive.ObjectID, image models.ProductImage) (models.Product, error) {
+	filter := bson.M{
+		"_id": productID,
+		"$expr": bson.M{"$lt": bson.A{
+			bson.M{"$size": bson.M{"$ifNull": bson.A{"$images", bson.A{}}}},
+			MaxProductImages,
+		}},
+	}
+
+	var product models.Product
+	err := productCollection.FindOneAndUpdate(ctx, filter, bson.M{"$push": bson.M{"images": image}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		count, countErr := productCollection.CountDocuments(ctx, bson.M{"_id": productID})
+		if countErr != nil {
+			return product, countErr
+		}
+		if count == 0 {
+			return product, ErrCantFindProduct
+		}
+		return product, ErrTooManyImages
+	}
+	if err != nil {
+		return product, err
+	}
+
+	return product, savePrimaryImage(ctx, productCollection, &product)
+}
+
+// ReorderProductImages puts the product's images in the order of imageIDs;
+// the first becomes the primary image.
+func ReorderProductImages(ctx context.Context, productCollection *mongo.Collection, productID primitive.ObjectID, imageIDs []primitive.ObjectID) (models.Product, error) {
+	product, err := GetProduct(ctx, productCollection, productID)
+	if err != nil {
+		return product, err
+	}
+	if len(imageIDs) != len(product.Images) {
+		return product, ErrInvalidImageOrder
+	}
+	if len(imageIDs) == 0 {
+		return product, nil
+	}
+
+	byID := make(map[primitive.ObjectID]models.ProductImage, len(product.Images))
+	for _, image := range product.Images {
+		byID[image.ID] = image
+	}
+
+	ordered := make([]models.ProductImage, 0, len(imageIDs))
+	for _, id := range imageIDs {
+		image, ok := byID[id]
+		if !ok {
+			return product, ErrInvalidImageOrder
+		}
+		delete(byID, id)
+		ordered = append(ordered, image)
+	}
+
+	product.Images = ordered
+	SyncPrimaryImage(&product)
+
+	// Only write over the images that were read: if one was uploaded or
+	// removed meanwhile, the order no longer covers every image.
+	filter := bson.M{
+		"_id":        productID,
+		"images":     bson.M{"$size": len(imageIDs)},
+		"images._id": bson.M{"$all": imageIDs},
+	}
+	result, err := productCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{
+		"images": product.Images,
+		"image":  product.Image,
+	}})
+	if err != nil {
+		return product, err
+	}
+	if result.MatchedCount == 0 {
+		return product, ErrInvalidImageOrder
+	}
+	return product, nil
+}
+
+// RemoveProductImage detaches an image from the product and returns it, so
+// the caller can delete its files.
+func RemoveProductImage(ctx context.Context, productCollection *mongo.Collection, productID, imageID primitive.ObjectID) (models.ProductImage, models.Product, error) {
+	var before models.Product
+	err := productCollection.FindOneAndUpdate(ctx,
+		bson.M{"_id": productID, "images._id": imageID},
+		bson.M{"$pull": bson.M{"images": bson.M{"_id": imageID}}},
+	).Decode(&before)
+	if err == mongo.ErrNoDocuments {
+		return models.ProductImage{}, before, ErrCantFindImage
+	}
+	if err != nil {
+		return models.ProductImage{}, before, err
+	}
+
+	var removed models.ProductImage
+	after := before
+	after.Images = nil
+	for _, image := range before.Images {
+		if image.ID == imageID {
+			removed = image
+		} else {
+			after.Images = append(after.Images, image)
+		}
+	}
+
+	return removed, after, savePrimaryImage(ctx, productCollection, &after)
+}
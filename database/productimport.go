@@ -0,0 +1,644 @@
+package database
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidImport     = errors.New("invalid product import")
+	ErrImportKeyRequired = errors.New("every row needs a sku or an id")
+	ErrUnknownFormat     = errors.New("format must be csv or jsonl")
+	ErrCantFindImportJob = errors.New("can't find the import job")
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+const (
+	ImportQueued    = "queued"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// MaxImportErrors caps the row errors kept for a job's error report.
+const MaxImportErrors = 1000
+
+// productCSVColumns is the column order of CSV exports. Attributes follow as
+// attr.<name> columns. id lets products without a sku be matched on import.
+// Options, variants, specifications and bundle hold JSON arrays, and
+// categories a |-separated list of ids.
+var productCSVColumns = []string{
+	"id", "sku", "product_name", "slug", "description", "price", "image", "status",
+	"publish_at", "unpublish_at", "categories", "options", "variants",
+	"specifications", "seo_title", "seo_description", "bundle",
+}
+
+var requiredCSVColumns = []string{"product_name", "price"}
+
+const attributeColumnPrefix = "attr."
+
+// ProductRows yields the products in an import one at a time, with their row
+// or line number. It returns io.EOF when done, an error wrapping
+// ErrInvalidImport when the file can't be read any further, and any other
+// error for a bad row.
+type ProductRows func() (int, models.Product, error)
+
+func NewProductRows(format string, r io.Reader) (ProductRows, error) {
+	switch format {
+	case FormatCSV:
+		return csvProductRows(r)
+	case FormatJSONL:
+		return jsonlProductRows(r), nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+func csvProductRows(r io.Reader) (ProductRows, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidImport)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredCSVColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%w: missing column %q", ErrInvalidImport, name)
+		}
+	}
+
+	row := 1
+	return func() (int, models.Product, error) {
+		row++
+		record, err := reader.Read()
+		if err == io.EOF {
+			return row, models.Product{}, io.EOF
+		}
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			return row, models.Product{}, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		if err != nil {
+			return row, models.Product{}, err
+		}
+
+		product, err := parseProductRecord(record, columns)
+		return row, product, err
+	}, nil
+}
+
+func parseProductRecord(record []string, columns map[string]int) (models.Product, error) {
+	var product models.Product
+
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	optional := func(name string) *string {
+		if value := field(name); value != "" {
+			return &value
+		}
+		return nil
+	}
+	timestamp := func(name string) (*time.Time, error) {
+		value := field(name)
+		if value == "" {
+			return nil, nil
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q, want RFC 3339", name, value)
+		}
+		return &parsed, nil
+	}
+
+	if value := field("id"); value != "" {
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return product, fmt.Errorf("invalid id %q", value)
+		}
+		product.ID = id
+	}
+	product.SKU = optional("sku")
+	product.Product_Name = optional("product_name")
+	product.Slug = field("slug")
+	product.Description = optional("description")
+	product.SEO_Title = optional("seo_title")
+	product.SEO_Description = optional("seo_description")
+	product.Image = optional("image")
+	product.Status = field("status")
+
+	if value := field("price"); value != "" {
+		price, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return product, fmt.Errorf("invalid price %q", value)
+		}
+		p := uint32(price)
+		product.Price = &p
+	}
+
+	var err error
+	if product.Publish_At, err = timestamp("publish_at"); err != nil {
+		return product, err
+	}
+	if product.Unpublish_At, err = timestamp("unpublish_at"); err != nil {
+		return product, err
+	}
+
+	if value := field("categories"); value != "" {
+		for _, hex := range strings.Split(value, "|") {
+			id, err := primitive.ObjectIDFromHex(strings.TrimSpace(hex))
+			if err != nil {
+				return product, fmt.Errorf("invalid category id %q", hex)
+			}
+			product.Categories = append(product.Categories, id)
+		}
+	}
+
+	if value := field("options"); value != "" {
+		if err := json.Unmarshal([]byte(value), &product.Options); err != nil {
+			return product, fmt.Errorf("invalid options: %v", err)
+		}
+	}
+	if value := field("variants"); value != "" {
+		if err := json.Unmarshal([]byte(value), &product.Variants); err != nil {
+			return product, fmt.Errorf("invalid variants: %v", err)
+		}
+	}
+	if value := field("specifications"); value != "" {
+		if err := json.Unmarshal([]byte(value), &product.Specifications); err != nil {
+			return product, fmt.Errorf("invalid specifications: %v", err)
+		}
+	}
+	if value := field("bundle"); value != "" {
+		if err := json.Unmarshal([]byte(value), &product.Bundle); err != nil {
+			return product, fmt.Errorf("invalid bundle: %v", err)
+		}
+	}
+
+	for name, i := range columns {
+		if !strings.HasPrefix(name, attributeColumnPrefix) || i >= len(record) {
+			continue
+		}
+		if value := strings.TrimSpace(record[i]); value != "" {
+			if product.Attributes == nil {
+				product.Attributes = map[string]string{}
+			}
+			product.Attributes[strings.TrimPrefix(name, attributeColumnPrefix)] = value
+		}
+	}
+
+	return product, nil
+}
+
+// maxImportLine bounds one JSON Lines record.
+const maxImportLine = 1 << 20
+
+func jsonlProductRows(r io.Reader) ProductRows {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportLine)
+
+	line := 0
+	return func() (int, models.Product, error) {
+		for scanner.Scan() {
+			line++
+			text := bytes.TrimSpace(scanner.Bytes())
+			if len(text) == 0 {
+				continue
+			}
+
+			var product models.Product
+			decoder := json.NewDecoder(bytes.NewReader(text))
+			decoder.DisallowUnknownFields()
+			err := decoder.Decode(&product)
+			return line, product, err
+		}
+		if err := scanner.Err(); err != nil {
+			return line + 1, models.Product{}, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+		}
+		return line, models.Product{}, io.EOF
+	}
+}
+
+type ImportResult struct {
+	Processed int
+	Created   int
+	Updated   int
+	Failed    int
+	Errors    []models.ImportRowError
+	Truncated bool
+}
+
+func (r *ImportResult) fail(row int, sku string, err error) {
+	r.Failed++
+	if len(r.Errors) >= MaxImportErrors {
+		r.Truncated = true
+		return
+	}
+	r.Errors = append(r.Errors, models.ImportRowError{Row: row, SKU: sku, Error: err.Error()})
+}
+
+// ImportProducts creates or updates one product per row, matched on its id
+// when it has one and otherwise on its sku. A row is the whole product:
+// fields it leaves out are cleared, except the ratings, which come from
+// reviews, uploaded images, and the slug, status and publish window, which
+// an existing product keeps when the row leaves them empty. Bad rows are
+// skipped and reported. With dryRun every row is checked but nothing is
+// written. saved is called after each write and progress every hundred rows.
+func ImportProducts(ctx context.Context, productCollection, categoryCollection *mongo.Collection, rows ProductRows, dryRun bool,
+	saved func(before *models.Product, after models.Product), progress func(ImportResult)) (ImportResult, error) {
+	var result ImportResult
+	seen := map[string]int{}
+
+	for {
+		row, product, err := rows()
+		if err == io.EOF {
+			return result, nil
+		}
+		if errors.Is(err, ErrInvalidImport) {
+			return result, err
+		}
+
+		result.Processed++
+		if result.Processed%100 == 0 && progress != nil {
+			progress(result)
+		}
+
+		sku := ""
+		if product.SKU != nil {
+			sku = strings.TrimSpace(*product.SKU)
+			product.SKU = &sku
+			if sku == "" {
+				product.SKU = nil
+			}
+		}
+		if err != nil {
+			result.fail(row, sku, err)
+			continue
+		}
+		if sku == "" && product.ID.IsZero() {
+			result.fail(row, sku, ErrImportKeyRequired)
+			continue
+		}
+
+		before, err := importProductRow(ctx, productCollection, categoryCollection, &product, row, seen)
+		if err != nil {
+			if IsInvalidProduct(err) || errors.Is(err, ErrInvalidCategoryIds) || errors.Is(err, errDuplicateInFile) {
+				result.fail(row, sku, err)
+				continue
+			}
+			return result, err
+		}
+
+		if before == nil {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+		if dryRun {
+			continue
+		}
+
+		if before == nil {
+			_, err = productCollection.InsertOne(ctx, product)
+		} else {
+			_, err = productCollection.ReplaceOne(ctx, bson.M{"_id": product.ID}, product)
+		}
+		if err != nil {
+			return result, err
+		}
+		if saved != nil {
+			saved(before, product)
+		}
+	}
+}
+
+var errDuplicateInFile = errors.New("sku or id appears earlier in the file")
+
+// importProductRow validates one imported product and matches it to the
+// stored product with the same id, or the same sku when the row has no id,
+// returning that product or nil if the row is new. A row with an id that
+// isn't stored yet is created with that id.
+func importProductRow(ctx context.Context, productCollection, categoryCollection *mongo.Collection, product *models.Product, row int, seen map[string]int) (*models.Product, error) {
+	var keys []string
+	match := bson.M{}
+	if !product.ID.IsZero() {
+		keys = append(keys, "id "+product.ID.Hex())
+		match["_id"] = product.ID
+	}
+	if product.SKU != nil {
+		keys = append(keys, *product.SKU)
+		if product.ID.IsZero() {
+			match["sku"] = *product.SKU
+		}
+	}
+	for _, variant := range product.Variants {
+		keys = append(keys, variant.SKU)
+	}
+	for _, key := range keys {
+		if earlier, ok := seen[key]; ok {
+			return nil, fmt.Errorf("%w: %q on row %d", errDuplicateInFile, key, earlier)
+		}
+	}
+
+	var before *models.Product
+	var existing models.Product
+	err := productCollection.FindOne(ctx, match).Decode(&existing)
+	switch {
+	case err == nil:
+		before = &existing
+		product.ID = existing.ID
+		if product.Slug == "" {
+			product.Slug = existing.Slug
+		}
+		if product.Status == "" {
+			// A row without a status leaves publishing alone rather than
+			// defaulting the product to published.
+			product.Status = existing.Status
+			product.Publish_At = existing.Publish_At
+			product.Unpublish_At = existing.Unpublish_At
+		}
+		product.Rating = existing.Rating
+		product.Rating_Average = existing.Rating_Average
+		product.Rating_Count = existing.Rating_Count
+		product.Images = existing.Images
+		SyncPrimaryImage(product)
+	case err == mongo.ErrNoDocuments:
+		if product.ID.IsZero() {
+			product.ID = primitive.NewObjectID()
+		}
+		product.Rating = nil
+		product.Rating_Average = 0
+		product.Rating_Count = 0
+		product.Images = nil
+	default:
+		return nil, err
+	}
+
+	if len(product.Categories) > 0 {
+		unique := map[primitive.ObjectID]bool{}
+		for _, id := range product.Categories {
+			unique[id] = true
+		}
+		ids := make([]primitive.ObjectID, 0, len(unique))
+		for id := range unique {
+			ids = append(ids, id)
+		}
+		count, err := categoryCollection.CountDocuments(ctx, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return nil, err
+		}
+		if int(count) != len(ids) {
+			return nil, ErrInvalidCategoryIds
+		}
+	}
+
+	if err := ValidateProduct(ctx, productCollection, product); err != nil {
+		return nil, err
+	}
+	if before != nil {
+		TrackSlugChange(*before, product)
+	}
+
+	for _, key := range keys {
+		seen[key] = row
+	}
+	return before, nil
+}
+
+// ExportProducts writes every product in the given format, in a shape
+// ImportProducts accepts back.
+func ExportProducts(ctx context.Context, productCollection *mongo.Collection, format string, w io.Writer) error {
+	if format != FormatCSV && format != FormatJSONL {
+		return ErrUnknownFormat
+	}
+
+	var attributes []string
+	if format == FormatCSV {
+		var err error
+		if attributes, err = productAttributeNames(ctx, productCollection); err != nil {
+			return err
+		}
+	}
+
+	cursor, err := productCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var csvWriter *csv.Writer
+	encoder := json.NewEncoder(w)
+	if format == FormatCSV {
+		csvWriter = csv.NewWriter(w)
+		header := append([]string{}, productCSVColumns...)
+		for _, name := range attributes {
+			header = append(header, attributeColumnPrefix+name)
+		}
+		if err := csvWriter.Write(header); err != nil {
+			return err
+		}
+	}
+
+	for cursor.Next(ctx) {
+		var product models.Product
+		if err := cursor.Decode(&product); err != nil {
+			return ErrCantDecodeProducts
+		}
+
+		if csvWriter == nil {
+			err = encoder.Encode(product)
+		} else {
+			err = csvWriter.Write(productRecord(product, attributes))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		if err := csvWriter.Error(); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+func productRecord(product models.Product, attributes []string) []string {
+	text := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	timestamp := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	jsonArray := func(v interface{}, n int) string {
+		if n == 0 {
+			return ""
+		}
+		encoded, _ := json.Marshal(v)
+		return string(encoded)
+	}
+
+	price := ""
+	if product.Price != nil {
+		price = strconv.FormatUint(uint64(*product.Price), 10)
+	}
+
+	categories := make([]string, 0, len(product.Categories))
+	for _, id := range product.Categories {
+		categories = append(categories, id.Hex())
+	}
+
+	record := []string{
+		product.ID.Hex(),
+		text(product.SKU),
+		text(product.Product_Name),
+		product.Slug,
+		text(product.Description),
+		price,
+		text(product.Image),
+		product.Status,
+		timestamp(product.Publish_At),
+		timestamp(product.Unpublish_At),
+		strings.Join(categories, "|"),
+		jsonArray(product.Options, len(product.Options)),
+		jsonArray(product.Variants, len(product.Variants)),
+		jsonArray(product.Specifications, len(product.Specifications)),
+		text(product.SEO_Title),
+		text(product.SEO_Description),
+		jsonArray(product.Bundle, len(product.Bundle)),
+	}
+	for _, name := range attributes {
+		record = append(record, product.Attributes[name])
+	}
+
+	return record
+}
+
+func productAttributeNames(ctx context.Context, productCollection *mongo.Collection) ([]string, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$project", Value: bson.M{"attributes": bson.M{"$objectToArray": bson.M{"$ifNull": bson.A{"$attributes", bson.M{}}}}}}},
+		{{Key: "$unwind", Value: "$attributes"}},
+		{{Key: "$group", Value: bson.M{"_id": "$attributes.k"}}},
+	}
+
+	cursor, err := productCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var names []string
+	for cursor.Next(ctx) {
+		var doc struct {
+			Name string `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		names = append(names, doc.Name)
+	}
+
+	sort.Strings(names)
+	return names, cursor.Err()
+}
+
+func CreateImportJob(ctx context.Context, importJobCollection *mongo.Collection, job *models.ImportJob) error {
+	job.ID = primitive.NewObjectID()
+	job.Status = ImportQueued
+	job.Errors = []models.ImportRowError{}
+	job.Created_At = time.Now()
+
+	_, err := importJobCollection.InsertOne(ctx, job)
+	return err
+}
+
+// UpdateImportJob records the progress, or with status set the outcome, of
+// an import job.
+func UpdateImportJob(ctx context.Context, importJobCollection *mongo.Collection, id primitive.ObjectID, status string, result ImportResult, failure error) error {
+	set := bson.M{
+		"status":           status,
+		"processed":        result.Processed,
+		"created":          result.Created,
+		"updated":          result.Updated,
+		"failed":           result.Failed,
+		"errors":           append([]models.ImportRowError{}, result.Errors...),
+		"errors_truncated": result.Truncated,
+	}
+	if status == ImportCompleted || status == ImportFailed {
+		set["finished_at"] = time.Now()
+	}
+	if failure != nil {
+		set["error"] = failure.Error()
+	}
+
+	_, err := importJobCollection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": set})
+	return err
+}
+
+// ErrImportInterrupted is recorded on jobs that were still queued or running
+// when the server stopped.
+var ErrImportInterrupted = errors.New("import was interrupted by a server restart; upload the file again")
+
+// FailInterruptedImportJobs marks every queued or running job as failed.
+// Jobs run in the server process and their files are held in memory, so at
+// startup none of them can still be making progress.
+func FailInterruptedImportJobs(ctx context.Context, importJobCollection *mongo.Collection) (int64, error) {
+	result, err := importJobCollection.UpdateMany(ctx,
+		bson.M{"status": bson.M{"$in": bson.A{ImportQueued, ImportRunning}}},
+		bson.M{"$set": bson.M{
+			"status":      ImportFailed,
+			"error":       ErrImportInterrupted.Error(),
+			"finished_at": time.Now(),
+		}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func GetImportJob(ctx context.Context, importJobCollection *mongo.Collection, id primitive.ObjectID) (models.ImportJob, error) {
+	var job models.ImportJob
+	err := importJobCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&job)
+	if err == mongo.ErrNoDocuments {
+		return job, ErrCantFindImportJob
+	}
+	return job, err
+}
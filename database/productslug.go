@@ -0,0 +1,154 @@
+package database
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureProductSlugIndexes makes current slugs unique and lets old ones be
+// looked up for redirects.
+func EnsureProductSlugIndexes(ctx context.Context, productCollection *mongo.Collection) error {
+	_, err := productCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "slug", Value: 1}},
+			Options: options.Index().SetName("product_slug").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"slug": bson.M{"$type": "string"}}),
+		},
+		{
+			Keys:    bson.D{{Key: "previous_slugs", Value: 1}},
+			Options: options.Index().SetName("product_previous_slugs"),
+		},
+	})
+	return err
+}
+
+// productSlugTaken reports whether another product uses slug, now or as a
+// slug it redirects from.
+func productSlugTaken(ctx context.Context, productCollection *mongo.Collection, slug string, exclude primitive.ObjectID) (bool, error) {
+	count, err := productCollection.CountDocuments(ctx, bson.M{
+		"_id": bson.M{"$ne": exclude},
+		"$or": bson.A{bson.M{"slug": slug}, bson.M{"previous_slugs": slug}},
+	})
+	return count > 0, err
+}
+
+// assignProductSlug normalises a chosen slug, which must be free, or derives
+// one from the product name, adding -2, -3 and so on until it is free.
+func assignProductSlug(ctx context.Context, productCollection *mongo.Collection, product *models.Product) error {
+	if product.Slug != "" {
+		product.Slug = Slugify(product.Slug)
+		if product.Slug == "" {
+			return ErrInvalidSlug
+		}
+
+		taken, err := productSlugTaken(ctx, productCollection, product.Slug, product.ID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrDuplicateSlug
+		}
+		return nil
+	}
+
+	base := ""
+	if product.Product_Name != nil {
+		base = Slugify(*product.Product_Name)
+	}
+	if base == "" {
+		base = "product"
+	}
+
+	for n := 1; ; n++ {
+		slug := base
+		if n > 1 {
+			slug += "-" + strconv.Itoa(n)
+		}
+
+		taken, err := productSlugTaken(ctx, productCollection, slug, product.ID)
+		if err != nil {
+			return err
+		}
+		if !taken {
+			product.Slug = slug
+			return nil
+		}
+	}
+}
+
+// BackfillProductSlugs gives every product that has no slug yet, such as
+// those created before slugs existed, one derived from its name.
+func BackfillProductSlugs(ctx context.Context, productCollection *mongo.Collection) (int, error) {
+	cursor, err := productCollection.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"slug": bson.M{"$exists": false}},
+		bson.M{"slug": ""},
+	}})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	filled := 0
+	for cursor.Next(ctx) {
+		var product models.Product
+		if err := cursor.Decode(&product); err != nil {
+			return filled, ErrCantDecodeProducts
+		}
+
+		product.Slug = ""
+		if err := assignProductSlug(ctx, productCollection, &product); err != nil {
+			return filled, err
+		}
+		_, err := productCollection.UpdateOne(ctx, bson.M{"_id": product.ID}, bson.M{"$set": bson.M{"slug": product.Slug}})
+		if mongo.IsDuplicateKeyError(err) {
+			// Another writer took the slug meanwhile; the next start
+			// picks this product up again.
+			continue
+		}
+		if err != nil {
+			return filled, err
+		}
+		filled++
+	}
+
+	return filled, cursor.Err()
+}
+
+// TrackSlugChange carries before's slugs over to after, so a product that
+// is renamed keeps answering to the slugs it had.
+func TrackSlugChange(before models.Product, after *models.Product) {
+	previous := []string{}
+	for _, slug := range append(before.Previous_Slugs, before.Slug) {
+		if slug == "" || slug == after.Slug {
+			continue
+		}
+		seen := false
+		for _, kept := range previous {
+			seen = seen || kept == slug
+		}
+		if !seen {
+			previous = append(previous, slug)
+		}
+	}
+	after.Previous_Slugs = previous
+}
+
+// GetProductBySlug finds a product by its current slug, or by a slug it used
+// to have, in which case moved is true.
+func GetProductBySlug(ctx context.Context, productCollection *mongo.Collection, slug string) (product models.Product, moved bool, err error) {
+	err = productCollection.FindOne(ctx, bson.M{"slug": slug}).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		moved = true
+		err = productCollection.FindOne(ctx, bson.M{"previous_slugs": slug}).Decode(&product)
+	}
+	if err == mongo.ErrNoDocuments {
+		return product, false, ErrCantFindProduct
+	}
+	return product, moved, err
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidPublishWindow = errors.New("scheduled products need a publish_at, and unpublish_at must come after it")
+	ErrProductNotAvailable  = errors.New("this product is not available")
+)
+
+const (
+	ProductDraft     = "draft"
+	ProductScheduled = "scheduled"
+	ProductPublished = "published"
+	ProductArchived  = "archived"
+)
+
+// LiveProductFilter matches the products customers can see at now:
+// published ones, and scheduled ones whose publish_at has passed, in both
+// cases until their unpublish_at. Products saved before statuses existed
+// have none and count as published.
+func LiveProductFilter(now time.Time) bson.M {
+	return bson.M{"$and": bson.A{
+		bson.M{"$or": bson.A{
+			bson.M{"status": bson.M{"$exists": false}},
+			bson.M{"status": ProductPublished},
+			bson.M{"status": ProductScheduled, "publish_at": bson.M{"$lte": now}},
+		}},
+		bson.M{"$or": bson.A{
+			bson.M{"unpublish_at": nil},
+			bson.M{"unpublish_at": bson.M{"$gt": now}},
+		}},
+	}}
+}
+
+// IsLive reports whether LiveProductFilter would match product at now.
+func IsLive(product models.Product, now time.Time) bool {
+	if product.Unpublish_At != nil && !product.Unpublish_At.After(now) {
+		return false
+	}
+
+	switch product.Status {
+	case "", ProductPublished:
+		return true
+	case ProductScheduled:
+		return product.Publish_At != nil && !product.Publish_At.After(now)
+	default:
+		return false
+	}
+}
+
+// ValidatePublishing defaults the status to published and checks the
+// publish window.
+func ValidatePublishing(product *models.Product) error {
+	if product.Status == "" {
+		product.Status = ProductPublished
+	}
+	if product.Status == ProductScheduled && product.Publish_At == nil {
+		return ErrInvalidPublishWindow
+	}
+	if product.Publish_At != nil && product.Unpublish_At != nil && !product.Unpublish_At.After(*product.Publish_At) {
+		return ErrInvalidPublishWindow
+	}
+	return nil
+}
+
+// SetProductStatus moves a product through the publishing workflow and
+// returns the updated product.
+func SetProductStatus(ctx context.Context, productCollection *mongo.Collection, productID primitive.ObjectID, status string, publishAt, unpublishAt *time.Time) (models.Product, error) {
+	product := models.Product{Status: status, Publish_At: publishAt, Unpublish_At: unpublishAt}
+	if err := ValidatePublishing(&product); err != nil {
+		return product, err
+	}
+
+	set := bson.M{"status": product.Status}
+	unset := bson.M{}
+	for field, value := range map[string]*time.Time{"publish_at": publishAt, "unpublish_at": unpublishAt} {
+		if value != nil {
+			set[field] = value
+		} else {
+			unset[field] = ""
+		}
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	var updated models.Product
+	err := productCollection.FindOneAndUpdate(ctx, bson.M{"_id": productID}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return updated, ErrCantFindProduct
+	}
+
+	return updated, err
+}
@@ -0,0 +1,226 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrCantFindQuestion = errors.New("can't find the question")
+	ErrCantFindAnswer   = errors.New("can't find the answer")
+)
+
+// CreateQuestion stores a shopper's question, pending moderation.
+func CreateQuestion(ctx context.Context, questionCollection *mongo.Collection, user models.User, productID primitive.ObjectID, question *models.Question) error {
+	_, purchased := PurchasedOrder(user, productID)
+
+	question.ID = primitive.NewObjectID()
+	question.Product_Id = productID
+	question.User_Id = user.ID.Hex()
+	question.Verified_Buyer = purchased
+	question.Status = ModerationPending
+	question.Upvotes = 0
+	question.Voters = []string{}
+	question.Answers = []models.Answer{}
+	question.Created_At = time.Now()
+
+	_, err := questionCollection.InsertOne(ctx, question)
+	return err
+}
+
+// AddAnswer attaches an answer to an approved question. Seller answers are
+// published straight away; everyone else's wait for moderation.
+func AddAnswer(ctx context.Context, questionCollection *mongo.Collection, questionID primitive.ObjectID, answer *models.Answer) error {
+	answer.ID = primitive.NewObjectID()
+	answer.Status = ModerationPending
+	if answer.Seller {
+		answer.Status = ModerationApproved
+	}
+	answer.Upvotes = 0
+	answer.Voters = []string{}
+	answer.Created_At = time.Now()
+
+	filter := bson.M{"_id": questionID, "status": ModerationApproved}
+	result, err := questionCollection.UpdateOne(ctx, filter, bson.M{"$push": bson.M{"answers": answer}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrCantFindQuestion
+	}
+
+	return nil
+}
+
+// GetQuestionProduct returns the product a question was asked on.
+func GetQuestionProduct(ctx context.Context, questionCollection *mongo.Collection, questionID primitive.ObjectID) (primitive.ObjectID, error) {
+	var question models.Question
+	err := questionCollection.FindOne(ctx, bson.M{"_id": questionID},
+		options.FindOne().SetProjection(bson.M{"product_id": 1})).Decode(&question)
+	if err == mongo.ErrNoDocuments {
+		return primitive.NilObjectID, ErrCantFindQuestion
+	}
+	return question.Product_Id, err
+}
+
+// ListQuestions returns a page of questions matching filter, most upvoted
+// first. With approvedOnly, unapproved answers are dropped; answers are
+// ordered by upvotes with seller answers first on ties.
+func ListQuestions(ctx context.Context, questionCollection *mongo.Collection, filter bson.M, skip, limit int64, approvedOnly bool) ([]models.Question, int64, error) {
+	total, err := questionCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "upvotes", Value: -1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: 1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+
+	cursor, err := questionCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	questions := []models.Question{}
+	if err = cursor.All(ctx, &questions); err != nil {
+		return nil, 0, err
+	}
+
+	for i := range questions {
+		answers := make([]models.Answer, 0, len(questions[i].Answers))
+		for _, answer := range questions[i].Answers {
+			if !approvedOnly || answer.Status == ModerationApproved {
+				answers = append(answers, answer)
+			}
+		}
+		sort.SliceStable(answers, func(a, b int) bool {
+			if answers[a].Upvotes != answers[b].Upvotes {
+				return answers[a].Upvotes > answers[b].Upvotes
+			}
+			return answers[a].Seller && !answers[b].Seller
+		})
+		questions[i].Answers = answers
+	}
+
+	return questions, total, nil
+}
+
+func ModerateQuestion(ctx context.Context, questionCollection *mongo.Collection, id primitive.ObjectID, status string) error {
+	if status != ModerationApproved && status != ModerationRejected {
+		return ErrInvalidModerationStatus
+	}
+
+	result, err := questionCollection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"status": status}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrCantFindQuestion
+	}
+
+	return nil
+}
+
+func ModerateAnswer(ctx context.Context, questionCollection *mongo.Collection, questionID, answerID primitive.ObjectID, status string) error {
+	if status != ModerationApproved && status != ModerationRejected {
+		return ErrInvalidModerationStatus
+	}
+
+	filter := bson.M{"_id": questionID, "answers._id": answerID}
+	result, err := questionCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"answers.$.status": status}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrCantFindAnswer
+	}
+
+	return nil
+}
+
+// VoteQuestion counts one upvote per user on an approved question. Askers
+// can't vote on their own questions.
+func VoteQuestion(ctx context.Context, questionCollection *mongo.Collection, id primitive.ObjectID, userID string) (int, error) {
+	filter := bson.M{
+		"_id":     id,
+		"status":  ModerationApproved,
+		"user_id": bson.M{"$ne": userID},
+		"voters":  bson.M{"$ne": userID},
+	}
+	update := bson.M{
+		"$addToSet": bson.M{"voters": userID},
+		"$inc":      bson.M{"upvotes": 1},
+	}
+
+	var question models.Question
+	err := questionCollection.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&question)
+	if err == nil {
+		return question.Upvotes, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return 0, err
+	}
+
+	count, err := questionCollection.CountDocuments(ctx, bson.M{"_id": id, "status": ModerationApproved})
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, ErrCantFindQuestion
+	}
+	return 0, ErrAlreadyVoted
+}
+
+// VoteAnswer counts one upvote per user on an approved answer.
+func VoteAnswer(ctx context.Context, questionCollection *mongo.Collection, questionID, answerID primitive.ObjectID, userID string) (int, error) {
+	filter := bson.M{
+		"_id": questionID,
+		"answers": bson.M{"$elemMatch": bson.M{
+			"_id":     answerID,
+			"status":  ModerationApproved,
+			"user_id": bson.M{"$ne": userID},
+			"voters":  bson.M{"$ne": userID},
+		}},
+	}
+	update := bson.M{
+		"$addToSet": bson.M{"answers.$.voters": userID},
+		"$inc":      bson.M{"answers.$.upvotes": 1},
+	}
+
+	var question models.Question
+	err := questionCollection.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&question)
+	if err == nil {
+		for _, answer := range question.Answers {
+			if answer.ID == answerID {
+				return answer.Upvotes, nil
+			}
+		}
+	}
+	if err != nil && err != mongo.ErrNoDocuments {
+		return 0, err
+	}
+
+	count, err := questionCollection.CountDocuments(ctx, bson.M{
+		"_id":     questionID,
+		"answers": bson.M{"$elemMatch": bson.M{"_id": answerID, "status": ModerationApproved}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, ErrCantFindAnswer
+	}
+	return 0, ErrAlreadyVoted
+}
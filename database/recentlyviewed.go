@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MaxRecentlyViewed caps how many products are remembered per user.
+const MaxRecentlyViewed = 50
+
+type RecentlyViewedProduct struct {
+	Product   models.Product `json:"product"`
+	Viewed_At time.Time      `json:"viewed_at"`
+}
+
+// RecordProductView moves productID to the front of the user's recently
+// viewed list, dropping its earlier view and the oldest views past
+// MaxRecentlyViewed.
+func RecordProductView(ctx context.Context, recentlyViewedCollection *mongo.Collection, userID string, productID primitive.ObjectID, viewedAt time.Time) error {
+	view := models.RecentView{Product_Id: productID, Viewed_At: viewedAt}
+	earlier := bson.M{"$filter": bson.M{
+		"input": bson.M{"$ifNull": bson.A{"$items", bson.A{}}},
+		"cond":  bson.M{"$ne": bson.A{"$$this.product_id", productID}},
+	}}
+
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"items":      bson.M{"$slice": bson.A{bson.M{"$concatArrays": bson.A{bson.A{view}, earlier}}, MaxRecentlyViewed}},
+		"updated_at": viewedAt,
+	}}}}
+
+	_, err := recentlyViewedCollection.UpdateOne(ctx, bson.M{"_id": userID}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// RecentlyViewed returns the user's most recently viewed products that are
+// still live, newest first, up to limit.
+func RecentlyViewed(ctx context.Context, recentlyViewedCollection, productCollection *mongo.Collection, userID string, limit int) ([]RecentlyViewedProduct, error) {
+	recent := []RecentlyViewedProduct{}
+
+	var doc struct {
+		Items []models.RecentView `bson:"items"`
+	}
+	err := recentlyViewedCollection.FindOne(ctx, bson.M{"_id": userID}).Decode(&doc)
+	if err == mongo.ErrNoDocuments || (err == nil && len(doc.Items) == 0) {
+		return recent, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(doc.Items))
+	for _, item := range doc.Items {
+		ids = append(ids, item.Product_Id)
+	}
+
+	filter := LiveProductFilter(time.Now())
+	filter["_id"] = bson.M{"$in": ids}
+	cursor, err := productCollection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var products []models.Product
+	if err = cursor.All(ctx, &products); err != nil {
+		return nil, ErrCantDecodeProducts
+	}
+
+	byID := make(map[primitive.ObjectID]models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+	for _, item := range doc.Items {
+		if product, ok := byID[item.Product_Id]; ok && len(recent) < limit {
+			recent = append(recent, RecentlyViewedProduct{Product: product, Viewed_At: item.Viewed_At})
+		}
+	}
+
+	return recent, nil
+}
+
+// ClearRecentlyViewed forgets one product the user viewed, or all of them
+// when productID is nil.
+func ClearRecentlyViewed(ctx context.Context, recentlyViewedCollection *mongo.Collection, userID string, productID *primitive.ObjectID) error {
+	if productID == nil {
+		_, err := recentlyViewedCollection.DeleteOne(ctx, bson.M{"_id": userID})
+		return err
+	}
+
+	_, err := recentlyViewedCollection.UpdateOne(ctx, bson.M{"_id": userID},
+		bson.M{"$pull": bson.M{"items": bson.M{"product_id": *productID}}})
+	return err
+}
@@ -0,0 +1,202 @@
+package database
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// MaxRelatedProducts caps each list kept per product.
+	MaxRelatedProducts = 10
+	// MinCoPurchases is how many orders must contain two products before
+	// they count as frequently bought together.
+	MinCoPurchases = 2
+)
+
+// Similarity weights for what two products have in common. Categories or
+// attribute values shared by more than maxSimilarityGroup products say too
+// little to be worth scoring.
+const (
+	sharedCategoryWeight  = 2
+	sharedAttributeWeight = 1
+	maxSimilarityGroup    = 1000
+)
+
+// CoPurchases counts, for every product, the orders across all users that
+// also contained each other product, keeping the top MaxRelatedProducts seen
+// in at least MinCoPurchases orders.
+func CoPurchases(ctx context.Context, userCollection *mongo.Collection) (map[primitive.ObjectID][]models.RelatedProduct, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$unwind", Value: "$order_status"}},
+		{{Key: "$project", Value: bson.M{"items": bson.M{"$setUnion": bson.A{"$order_status.order_cart._id", bson.A{}}}}}},
+		{{Key: "$match", Value: bson.M{"items.1": bson.M{"$exists": true}}}},
+		{{Key: "$project", Value: bson.M{"a": "$items", "b": "$items"}}},
+		{{Key: "$unwind", Value: "$a"}},
+		{{Key: "$unwind", Value: "$b"}},
+		{{Key: "$match", Value: bson.M{"$expr": bson.M{"$ne": bson.A{"$a", "$b"}}}}},
+		{{Key: "$group", Value: bson.M{"_id": bson.M{"a": "$a", "b": "$b"}, "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gte": MinCoPurchases}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.a", Value: 1}, {Key: "count", Value: -1}, {Key: "_id.b", Value: 1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      "$_id.a",
+			"together": bson.M{"$push": bson.M{"product_id": "$_id.b", "score": "$count"}},
+		}}},
+		{{Key: "$project", Value: bson.M{"together": bson.M{"$slice": bson.A{"$together", MaxRelatedProducts}}}}},
+	}
+
+	cursor, err := userCollection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	together := map[primitive.ObjectID][]models.RelatedProduct{}
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID       primitive.ObjectID      `bson:"_id"`
+			Together []models.RelatedProduct `bson:"together"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		together[doc.ID] = doc.Together
+	}
+
+	return together, cursor.Err()
+}
+
+// SimilarProducts scores every pair of products by the categories and
+// attribute values they share, keeping the top MaxRelatedProducts for each.
+func SimilarProducts(products []models.Product) map[primitive.ObjectID][]models.RelatedProduct {
+	type feature struct {
+		key    string
+		weight float64
+	}
+
+	features := make(map[primitive.ObjectID][]feature, len(products))
+	groups := map[string][]primitive.ObjectID{}
+	for _, product := range products {
+		var own []feature
+		for _, category := range product.Categories {
+			own = append(own, feature{"c:" + category.Hex(), sharedCategoryWeight})
+		}
+		for name, value := range product.Attributes {
+			own = append(own, feature{"a:" + name + "=" + value, sharedAttributeWeight})
+		}
+		features[product.ID] = own
+		for _, f := range own {
+			groups[f.key] = append(groups[f.key], product.ID)
+		}
+	}
+
+	similar := make(map[primitive.ObjectID][]models.RelatedProduct, len(products))
+	for _, product := range products {
+		scores := map[primitive.ObjectID]float64{}
+		for _, f := range features[product.ID] {
+			if len(groups[f.key]) > maxSimilarityGroup {
+				continue
+			}
+			for _, other := range groups[f.key] {
+				if other != product.ID {
+					scores[other] += f.weight
+				}
+			}
+		}
+
+		related := make([]models.RelatedProduct, 0, len(scores))
+		for id, score := range scores {
+			related = append(related, models.RelatedProduct{Product_Id: id, Score: score})
+		}
+		sort.Slice(related, func(i, j int) bool {
+			if related[i].Score != related[j].Score {
+				return related[i].Score > related[j].Score
+			}
+			return related[i].Product_Id.Hex() < related[j].Product_Id.Hex()
+		})
+		if len(related) > MaxRelatedProducts {
+			related = related[:MaxRelatedProducts]
+		}
+		if len(related) > 0 {
+			similar[product.ID] = related
+		}
+	}
+
+	return similar
+}
+
+// SaveProductAssociations replaces the stored associations with the given
+// ones, removing those for products that no longer have any.
+func SaveProductAssociations(ctx context.Context, associationCollection *mongo.Collection, associations []models.ProductAssociation, computedAt time.Time) error {
+	if len(associations) > 0 {
+		writes := make([]mongo.WriteModel, 0, len(associations))
+		for _, association := range associations {
+			writes = append(writes, mongo.NewReplaceOneModel().
+				SetFilter(bson.M{"_id": association.ID}).
+				SetReplacement(association).
+				SetUpsert(true))
+		}
+		if _, err := associationCollection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			return err
+		}
+	}
+
+	_, err := associationCollection.DeleteMany(ctx, bson.M{"computed_at": bson.M{"$lt": computedAt}})
+	return err
+}
+
+// GetProductAssociation returns the stored associations for a product, which
+// are empty until the next rebuild after it is first sold or added.
+func GetProductAssociation(ctx context.Context, associationCollection *mongo.Collection, productID primitive.ObjectID) (models.ProductAssociation, error) {
+	association := models.ProductAssociation{ID: productID}
+	err := associationCollection.FindOne(ctx, bson.M{"_id": productID}).Decode(&association)
+	if err == mongo.ErrNoDocuments {
+		return association, nil
+	}
+	return association, err
+}
+
+// RelatedProductList loads the live products among related, in order, up to
+// limit.
+func RelatedProductList(ctx context.Context, productCollection *mongo.Collection, related []models.RelatedProduct, limit int) ([]models.Product, error) {
+	products := []models.Product{}
+	if len(related) == 0 {
+		return products, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(related))
+	for _, item := range related {
+		ids = append(ids, item.Product_Id)
+	}
+
+	filter := LiveProductFilter(time.Now())
+	filter["_id"] = bson.M{"$in": ids}
+	cursor, err := productCollection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var found []models.Product
+	if err = cursor.All(ctx, &found); err != nil {
+		return nil, ErrCantDecodeProducts
+	}
+
+	byID := make(map[primitive.ObjectID]models.Product, len(found))
+	for _, product := range found {
+		byID[product.ID] = product
+	}
+	for _, id := range ids {
+		if product, ok := byID[id]; ok && len(products) < limit {
+			products = append(products, product)
+		}
+	}
+
+	return products, nil
+}
@@ -0,0 +1,224 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrNotPurchased            = errors.New("only customers who ordered this product can review it")
+	ErrAlreadyReviewed         = errors.New("you have already reviewed this product")
+	ErrCantFindReview          = errors.New("can't find the review")
+	ErrInvalidModerationStatus = errors.New("status must be approved or rejected")
+	ErrAlreadyVoted            = errors.New("you can't vote twice or on your own post")
+)
+
+// Moderation statuses shared by reviews, questions and answers.
+const (
+	ModerationPending  = "pending"
+	ModerationApproved = "approved"
+	ModerationRejected = "rejected"
+)
+
+// EnsureReviewIndexes allows one review per user and product, so concurrent
+// submissions can't both be stored.
+func EnsureReviewIndexes(ctx context.Context, reviewCollection *mongo.Collection) error {
+	_, err := reviewCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "product_id", Value: 1}, {Key: "user_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
+
+// PurchasedOrder returns the first of the user's orders containing the
+// product.
+func PurchasedOrder(user models.User, productID primitive.ObjectID) (primitive.ObjectID, bool) {
+	for _, order := range user.Order_Status {
+		for _, item := range order.Order_Cart {
+			if item.ID == productID {
+				return order.ID, true
+			}
+		}
+	}
+	return primitive.NilObjectID, false
+}
+
+// CreateReview stores a review from a verified buyer, pending moderation.
+func CreateReview(ctx context.Context, reviewCollection *mongo.Collection, user models.User, productID primitive.ObjectID, review *models.Review) error {
+	orderID, ok := PurchasedOrder(user, productID)
+	if !ok {
+		return ErrNotPurchased
+	}
+
+	existing, err := reviewCollection.CountDocuments(ctx, bson.M{"product_id": productID, "user_id": user.ID.Hex()})
+	if err != nil {
+		return err
+	}
+	if existing > 0 {
+		return ErrAlreadyReviewed
+	}
+
+	review.ID = primitive.NewObjectID()
+	review.Product_Id = productID
+	review.User_Id = user.ID.Hex()
+	review.Order_Id = orderID
+	review.Status = ModerationPending
+	review.Helpful_Votes = 0
+	review.Voters = []string{}
+	review.Created_At = time.Now()
+	review.Moderated_At = nil
+
+	_, err = reviewCollection.InsertOne(ctx, review)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAlreadyReviewed
+	}
+	return err
+}
+
+// ListReviews returns a page of reviews matching filter, most helpful first,
+// along with the total number of matches.
+func ListReviews(ctx context.Context, reviewCollection *mongo.Collection, filter bson.M, skip, limit int64) ([]models.Review, int64, error) {
+	total, err := reviewCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "helpful_votes", Value: -1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: 1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+
+	cursor, err := reviewCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	reviews := []models.Review{}
+	if err = cursor.All(ctx, &reviews); err != nil {
+		return nil, 0, err
+	}
+
+	return reviews, total, nil
+}
+
+// ModerateReview approves or rejects a review and refreshes the product's
+// rating.
+func ModerateReview(ctx context.Context, reviewCollection, productCollection *mongo.Collection, id primitive.ObjectID, status string) (models.Review, error) {
+	var review models.Review
+	if status != ModerationApproved && status != ModerationRejected {
+		return review, ErrInvalidModerationStatus
+	}
+
+	update := bson.M{"$set": bson.M{"status": status, "moderated_at": time.Now()}}
+	err := reviewCollection.FindOneAndUpdate(ctx, bson.M{"_id": id}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&review)
+	if err == mongo.ErrNoDocuments {
+		return review, ErrCantFindReview
+	}
+	if err != nil {
+		return review, err
+	}
+
+	return review, RefreshProductRating(ctx, reviewCollection, productCollection, review.Product_Id)
+}
+
+// VoteReviewHelpful counts one helpful vote per user on an approved review.
+// Authors can't vote on their own reviews.
+func VoteReviewHelpful(ctx context.Context, reviewCollection *mongo.Collection, id primitive.ObjectID, userID string) (int, error) {
+	filter := bson.M{
+		"_id":     id,
+		"status":  ModerationApproved,
+		"user_id": bson.M{"$ne": userID},
+		"voters":  bson.M{"$ne": userID},
+	}
+	update := bson.M{
+		"$addToSet": bson.M{"voters": userID},
+		"$inc":      bson.M{"helpful_votes": 1},
+	}
+
+	var review models.Review
+	err := reviewCollection.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&review)
+	if err == nil {
+		return review.Helpful_Votes, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return 0, err
+	}
+
+	count, err := reviewCollection.CountDocuments(ctx, bson.M{"_id": id, "status": ModerationApproved})
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, ErrCantFindReview
+	}
+	return 0, ErrAlreadyVoted
+}
+
+// RefreshProductRating recomputes a product's average rating and rating
+// count from its approved reviews.
+func RefreshProductRating(ctx context.Context, reviewCollection, productCollection *mongo.Collection, productID primitive.ObjectID) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"product_id": productID, "status": ModerationApproved}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     nil,
+			"average": bson.M{"$avg": "$rating"},
+			"count":   bson.M{"$sum": 1},
+		}}},
+	}
+
+	cursor, err := reviewCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var stats []struct {
+		Average float64 `bson:"average"`
+		Count   int     `bson:"count"`
+	}
+	if err = cursor.All(ctx, &stats); err != nil {
+		return err
+	}
+
+	update := bson.M{
+		"$set":   bson.M{"rating_average": 0.0, "rating_count": 0},
+		"$unset": bson.M{"rating": ""},
+	}
+	if len(stats) > 0 && stats[0].Count > 0 {
+		update = bson.M{"$set": bson.M{
+			"rating_average": math.Round(stats[0].Average*10) / 10,
+			"rating_count":   stats[0].Count,
+			"rating":         uint8(math.Round(stats[0].Average)),
+		}}
+	}
+
+	_, err = productCollection.UpdateOne(ctx, bson.M{"_id": productID}, update)
+	return err
+}
+
+// BackfillRatingAverages gives products stored before ratings came from
+// reviews a rating_average taken from their static rating, so rating sorts
+// and filters don't treat them as unrated. Returns how many were updated.
+func BackfillRatingAverages(ctx context.Context, productCollection *mongo.Collection) (int64, error) {
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"rating_average": bson.M{"$ifNull": bson.A{bson.M{"$toDouble": "$rating"}, 0.0}},
+		"rating_count":   bson.M{"$ifNull": bson.A{"$rating_count", 0}},
+	}}}}
+
+	result, err := productCollection.UpdateMany(ctx, bson.M{"rating_average": bson.M{"$exists": false}}, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
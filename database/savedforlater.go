@@ -0,0 +1,154 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrCantFindCartItem  = errors.New("product isn't in the cart")
+	ErrCantFindSavedItem = errors.New("product isn't saved for later")
+)
+
+func cartLineMatch(productID primitive.ObjectID, sku *string) bson.M {
+	return bson.M{"_id": productID, "sku": sku}
+}
+
+// cartLineExpr is cartLineMatch as an aggregation expression on $$this, for
+// filtering cart arrays inside an update pipeline.
+func cartLineExpr(productID primitive.ObjectID, sku *string) bson.M {
+	return bson.M{"$and": bson.A{
+		bson.M{"$eq": bson.A{"$$this._id", productID}},
+		bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$$this.sku", nil}}, sku}},
+	}}
+}
+
+// moveCartLines is an update pipeline moving every unit of a line from one
+// array of the user to the other in a single write, so a unit added to
+// either array meanwhile is neither lost nor moved twice. With replacement
+// set, each moved unit is written as replacement instead.
+func moveCartLines(from, to string, productID primitive.ObjectID, sku *string, replacement *models.ProductUser) mongo.Pipeline {
+	source := bson.M{"$ifNull": bson.A{"$" + from, bson.A{}}}
+	var moved interface{} = bson.M{"$filter": bson.M{"input": source, "cond": cartLineExpr(productID, sku)}}
+	if replacement != nil {
+		moved = bson.M{"$map": bson.M{"input": moved, "in": bson.M{"$literal": *replacement}}}
+	}
+
+	return mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		to:   bson.M{"$concatArrays": bson.A{bson.M{"$ifNull": bson.A{"$" + to, bson.A{}}}, moved}},
+		from: bson.M{"$filter": bson.M{"input": source, "cond": bson.M{"$not": bson.A{cartLineExpr(productID, sku)}}}},
+	}}}}
+}
+
+func matchingLines(lines []models.ProductUser, productID primitive.ObjectID, sku *string) []models.ProductUser {
+	var matched []models.ProductUser
+	for _, line := range lines {
+		if line.ID == productID && sameString(line.SKU, sku) {
+			matched = append(matched, line)
+		}
+	}
+	return matched
+}
+
+// SaveForLater moves a cart line, with every unit of it, out of the cart
+// into the user's saved for later list. Saved lines aren't part of checkout.
+func SaveForLater(ctx context.Context, userCollection *mongo.Collection, userID string, productID primitive.ObjectID, sku *string) error {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return ErrUserIdIsNotValid
+	}
+
+	filter := bson.M{"_id": userObjID, "user_cart": bson.M{"$elemMatch": cartLineMatch(productID, sku)}}
+	result, err := userCollection.UpdateOne(ctx, filter, moveCartLines("user_cart", "saved_for_later", productID, sku, nil))
+	if err != nil {
+		return ErrCantUpdateUser
+	}
+	if result.MatchedCount == 0 {
+		return ErrCantFindCartItem
+	}
+	return nil
+}
+
+// MoveToCart puts a saved line back in the cart at the current price, as
+// long as the product can still be bought.
+func MoveToCart(ctx context.Context, productCollection, userCollection *mongo.Collection, userID string, productID primitive.ObjectID, sku *string) error {
+	user, err := GetUser(ctx, userCollection, userID)
+	if err != nil {
+		return err
+	}
+
+	lines := matchingLines(user.Saved_For_Later, productID, sku)
+	if len(lines) == 0 {
+		return ErrCantFindSavedItem
+	}
+
+	product, err := GetProduct(ctx, productCollection, productID)
+	if err != nil {
+		return err
+	}
+	if !IsLive(product, time.Now()) {
+		return ErrProductNotAvailable
+	}
+
+	line := models.ProductUser{
+		ID:           product.ID,
+		Product_Name: product.Product_Name,
+		Price:        product.Price,
+		Rating:       product.Rating,
+		Image:        product.Image,
+	}
+	switch {
+	case sku != nil:
+		variant, ok := FindVariant(product, *sku)
+		if !ok {
+			return ErrCantFindVariant
+		}
+		if variant.Stock == nil || *variant.Stock < len(lines) {
+			return ErrOutOfStock
+		}
+		line = VariantCartLine(product, variant)
+	case len(product.Variants) > 0:
+		return ErrSKURequired
+	case len(product.Bundle) > 0:
+		bundle, err := ResolveBundle(ctx, productCollection, product)
+		if err != nil {
+			return err
+		}
+		if bundle.Stock != nil && *bundle.Stock < len(lines) {
+			return ErrOutOfStock
+		}
+	}
+
+	filter := bson.M{"_id": user.ID, "saved_for_later": bson.M{"$elemMatch": cartLineMatch(productID, sku)}}
+	result, err := userCollection.UpdateOne(ctx, filter, moveCartLines("saved_for_later", "user_cart", productID, sku, &line))
+	if err != nil {
+		return ErrCantUpdateUser
+	}
+	if result.MatchedCount == 0 {
+		return ErrCantFindSavedItem
+	}
+	return nil
+}
+
+func RemoveSavedItem(ctx context.Context, userCollection *mongo.Collection, userID string, productID primitive.ObjectID, sku *string) error {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return ErrUserIdIsNotValid
+	}
+
+	filter := bson.M{"_id": userObjID, "saved_for_later": bson.M{"$elemMatch": cartLineMatch(productID, sku)}}
+	result, err := userCollection.UpdateOne(ctx, filter, bson.M{"$pull": bson.M{"saved_for_later": cartLineMatch(productID, sku)}})
+	if err != nil {
+		return ErrCantUpdateUser
+	}
+	if result.MatchedCount == 0 {
+		return ErrCantFindSavedItem
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/djwhocodes/ecom_cart_golang/search"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrEmptySearchQuery = errors.New("search query is empty")
+
+type SearchQuery struct {
+	Terms   []string
+	Phrases []string
+	Exclude []string
+}
+
+type SearchHit struct {
+	Product    models.Product    `json:"product"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// ParseSearchQuery splits a user query into quoted phrases, -excluded terms
+// and plain terms.
+func ParseSearchQuery(raw string) SearchQuery {
+	var query SearchQuery
+
+	parts := strings.Split(raw, `"`)
+	for i, part := range parts {
+		if i%2 == 1 {
+			if phrase := strings.TrimSpace(part); phrase != "" {
+				query.Phrases = append(query.Phrases, phrase)
+			}
+			continue
+		}
+		for _, term := range strings.Fields(part) {
+			if strings.HasPrefix(term, "-") {
+				if term = strings.TrimPrefix(term, "-"); term != "" {
+					query.Exclude = append(query.Exclude, term)
+				}
+				continue
+			}
+			query.Terms = append(query.Terms, term)
+		}
+	}
+
+	return query
+}
+
+func (q SearchQuery) Empty() bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0
+}
+
+// TextSearch renders the query in $text syntax. With matchAll every term is
+// quoted, since $text ANDs phrases but ORs bare terms.
+func (q SearchQuery) TextSearch(matchAll bool) string {
+	var parts []string
+	for _, term := range q.Terms {
+		if matchAll {
+			parts = append(parts, `"`+term+`"`)
+		} else {
+			parts = append(parts, term)
+		}
+	}
+	for _, phrase := range q.Phrases {
+		parts = append(parts, `"`+phrase+`"`)
+	}
+	for _, term := range q.Exclude {
+		parts = append(parts, "-"+term)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Highlight HTML-escapes text and wraps every occurrence of the query's terms
+// and phrases in <em> tags, matching case-insensitively at word starts.
+func (q SearchQuery) Highlight(text string) (string, bool) {
+	words := append(append([]string{}, q.Phrases...), q.Terms...)
+	if len(words) == 0 || text == "" {
+		return text, false
+	}
+
+	sort.Slice(words, func(i, j int) bool { return len(words[i]) > len(words[j]) })
+
+	patterns := make([]string, 0, len(words))
+	for _, word := range words {
+		patterns = append(patterns, regexp.QuoteMeta(html.EscapeString(word)))
+	}
+	text = html.EscapeString(text)
+	re := regexp.MustCompile(`(?i)\b(` + strings.Join(patterns, "|") + `)`)
+
+	if !re.MatchString(text) {
+		return text, false
+	}
+	return re.ReplaceAllString(text, "<em>$1</em>"), true
+}
+
+// SearchText is the denormalised text indexed alongside name and description,
+// so attribute values are searchable without a wildcard index.
+func SearchText(product models.Product) string {
+	values := make([]string, 0, len(product.Attributes))
+	for _, value := range product.Attributes {
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return strings.Join(values, " ")
+}
+
+func EnsureProductTextIndex(ctx context.Context, productCollection *mongo.Collection) error {
+	index := mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "product_name", Value: "text"},
+			{Key: "description", Value: "text"},
+			{Key: "search_text", Value: "text"},
+		},
+		Options: options.Index().
+			SetName("product_text").
+			SetWeights(bson.M{"product_name": 10, "search_text": 3, "description": 1}),
+	}
+
+	_, err := productCollection.Indexes().CreateOne(ctx, index)
+	return err
+}
+
+// maxTextSearchResults caps how many matches a database text search ranks.
+const maxTextSearchResults = 1000
+
+// TextSearchRanking runs query against the product text index and returns
+// the matching IDs ordered by relevance.
+func TextSearchRanking(ctx context.Context, productCollection *mongo.Collection, query SearchQuery, matchAll bool) ([]search.Hit, error) {
+	if query.Empty() {
+		return nil, ErrEmptySearchQuery
+	}
+
+	filter := LiveProductFilter(time.Now())
+	filter["$text"] = bson.M{"$search": query.TextSearch(matchAll)}
+
+	opts := options.Find().
+		SetProjection(bson.M{"_id": 1, "score": bson.M{"$meta": "textScore"}}).
+		SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}, {Key: "_id", Value: 1}}).
+		SetLimit(maxTextSearchResults)
+
+	cursor, err := productCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	hits := []search.Hit{}
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID    primitive.ObjectID `bson:"_id"`
+			Score float64            `bson:"score"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, ErrCantDecodeProducts
+		}
+		hits = append(hits, search.Hit{ID: doc.ID, Score: doc.Score})
+	}
+
+	return hits, cursor.Err()
+}
+
+func HighlightProduct(query SearchQuery, product models.Product) map[string]string {
+	highlights := map[string]string{}
+	if product.Product_Name != nil {
+		if text, ok := query.Highlight(*product.Product_Name); ok {
+			highlights["product_name"] = text
+		}
+	}
+	if product.Description != nil {
+		if text, ok := query.Highlight(*product.Description); ok {
+			highlights["description"] = text
+		}
+	}
+	for name, value := range product.Attributes {
+		if text, ok := query.Highlight(value); ok {
+			highlights["attributes."+name] = text
+		}
+	}
+	return highlights
+}
+
+func FindProductsByIDs(ctx context.Context, productCollection *mongo.Collection, ids []primitive.ObjectID) (map[primitive.ObjectID]models.Product, error) {
+	cursor, err := productCollection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var products []models.Product
+	if err = cursor.All(ctx, &products); err != nil {
+		return nil, ErrCantDecodeProducts
+	}
+
+	byID := make(map[primitive.ObjectID]models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+
+	return byID, nil
+}
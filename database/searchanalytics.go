@@ -0,0 +1,150 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrCantFindSearchEvent = errors.New("can't find the search")
+
+type QueryCount struct {
+	Query       string  `json:"query" bson:"_id"`
+	Searches    int64   `json:"searches" bson:"searches"`
+	Avg_Results float64 `json:"avg_results" bson:"avg_results"`
+}
+
+type SearchConversion struct {
+	Searches       int64   `json:"searches" bson:"searches"`
+	With_Click     int64   `json:"with_click" bson:"with_click"`
+	With_Cart      int64   `json:"with_cart" bson:"with_cart"`
+	Click_Rate     float64 `json:"click_rate" bson:"-"`
+	Search_To_Cart float64 `json:"search_to_cart" bson:"-"`
+}
+
+type SearchReport struct {
+	From                time.Time        `json:"from"`
+	To                  time.Time        `json:"to"`
+	Top_Queries         []QueryCount     `json:"top_queries"`
+	Zero_Result_Queries []QueryCount     `json:"zero_result_queries"`
+	Conversion          SearchConversion `json:"conversion"`
+}
+
+// RecordSearchEvent logs a search once, on its first page. redirect is the
+// category slug when a query rule sent the search there instead.
+func RecordSearchEvent(ctx context.Context, searchEventCollection *mongo.Collection, query string, resultCount int64, userID, redirect string) (primitive.ObjectID, error) {
+	event := models.SearchEvent{
+		ID:               primitive.NewObjectID(),
+		Query:            NormalizeSearchQuery(query),
+		Result_Count:     resultCount,
+		User_Id:          userID,
+		Clicked_Products: []primitive.ObjectID{},
+		Carted_Products:  []primitive.ObjectID{},
+		Redirect:         redirect,
+		Searched_At:      time.Now(),
+	}
+
+	_, err := searchEventCollection.InsertOne(ctx, event)
+	return event.ID, err
+}
+
+// RecordSearchOutcome notes that a product from the search results was
+// clicked, or added to the cart when carted is set.
+func RecordSearchOutcome(ctx context.Context, searchEventCollection *mongo.Collection, searchID string, productID primitive.ObjectID, carted bool) error {
+	searchObjID, err := primitive.ObjectIDFromHex(searchID)
+	if err != nil {
+		return ErrCantFindSearchEvent
+	}
+
+	field := "clicked_products"
+	if carted {
+		field = "carted_products"
+	}
+
+	result, err := searchEventCollection.UpdateOne(ctx, bson.M{"_id": searchObjID}, bson.M{"$addToSet": bson.M{field: productID}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrCantFindSearchEvent
+	}
+
+	return nil
+}
+
+func BuildSearchReport(ctx context.Context, searchEventCollection *mongo.Collection, from, to time.Time, limit int64) (SearchReport, error) {
+	report := SearchReport{From: from, To: to, Top_Queries: []QueryCount{}, Zero_Result_Queries: []QueryCount{}}
+
+	byQuery := func(match bson.M) bson.A {
+		return bson.A{
+			bson.M{"$match": match},
+			bson.M{"$group": bson.M{
+				"_id":         "$query",
+				"searches":    bson.M{"$sum": 1},
+				"avg_results": bson.M{"$avg": "$result_count"},
+			}},
+			bson.M{"$sort": bson.D{{Key: "searches", Value: -1}, {Key: "_id", Value: 1}}},
+			bson.M{"$limit": limit},
+		}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"searched_at": bson.M{"$gte": from, "$lt": to}}}},
+		{{Key: "$facet", Value: bson.M{
+			"top":  byQuery(bson.M{}),
+			"zero": byQuery(bson.M{"result_count": 0, "redirect": bson.M{"$exists": false}}),
+			"conversion": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":      nil,
+					"searches": bson.M{"$sum": 1},
+					"with_click": bson.M{"$sum": bson.M{"$cond": bson.A{
+						bson.M{"$gt": bson.A{bson.M{"$size": "$clicked_products"}, 0}}, 1, 0,
+					}}},
+					"with_cart": bson.M{"$sum": bson.M{"$cond": bson.A{
+						bson.M{"$gt": bson.A{bson.M{"$size": "$carted_products"}, 0}}, 1, 0,
+					}}},
+				}},
+			},
+		}}},
+	}
+
+	cursor, err := searchEventCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return report, err
+	}
+	defer cursor.Close(ctx)
+
+	var out []struct {
+		Top        []QueryCount       `bson:"top"`
+		Zero       []QueryCount       `bson:"zero"`
+		Conversion []SearchConversion `bson:"conversion"`
+	}
+	if err = cursor.All(ctx, &out); err != nil {
+		return report, err
+	}
+	if len(out) == 0 {
+		return report, nil
+	}
+
+	if out[0].Top != nil {
+		report.Top_Queries = out[0].Top
+	}
+	if out[0].Zero != nil {
+		report.Zero_Result_Queries = out[0].Zero
+	}
+	if len(out[0].Conversion) > 0 {
+		conversion := out[0].Conversion[0]
+		if conversion.Searches > 0 {
+			conversion.Click_Rate = float64(conversion.With_Click) / float64(conversion.Searches)
+			conversion.Search_To_Cart = float64(conversion.With_Cart) / float64(conversion.Searches)
+		}
+		report.Conversion = conversion
+	}
+
+	return report, nil
+}
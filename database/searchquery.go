@@ -0,0 +1,51 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func NormalizeSearchQuery(query string) string {
+	return strings.Join(strings.Fields(strings.ToLower(query)), " ")
+}
+
+// RecordSearchQuery bumps the popularity of a query that returned results.
+func RecordSearchQuery(ctx context.Context, searchQueryCollection *mongo.Collection, query string) error {
+	query = NormalizeSearchQuery(query)
+	if query == "" {
+		return nil
+	}
+
+	update := bson.M{
+		"$inc": bson.M{"count": 1},
+		"$set": bson.M{"last_searched_at": time.Now()},
+	}
+
+	_, err := searchQueryCollection.UpdateOne(ctx, bson.M{"query": query}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+// PopularSearchQueries returns the most searched queries seen at least
+// minCount times, most popular first.
+func PopularSearchQueries(ctx context.Context, searchQueryCollection *mongo.Collection, minCount, limit int64) ([]models.SearchQueryStat, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "count", Value: -1}}).SetLimit(limit)
+
+	cursor, err := searchQueryCollection.Find(ctx, bson.M{"count": bson.M{"$gte": minCount}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var stats []models.SearchQueryStat
+	if err = cursor.All(ctx, &stats); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrCantFindSynonymSet = errors.New("can't find the synonym set")
+	ErrCantFindQueryRule  = errors.New("can't find the query rule")
+	ErrInvalidQueryRule   = errors.New("redirect rules need a category and other rules need products")
+)
+
+func ListSynonymSets(ctx context.Context, synonymCollection *mongo.Collection) ([]models.SynonymSet, error) {
+	cursor, err := synonymCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sets := []models.SynonymSet{}
+	if err = cursor.All(ctx, &sets); err != nil {
+		return nil, err
+	}
+
+	return sets, nil
+}
+
+func CreateSynonymSet(ctx context.Context, synonymCollection *mongo.Collection, set *models.SynonymSet) error {
+	set.ID = primitive.NewObjectID()
+	set.Created_At = time.Now()
+
+	_, err := synonymCollection.InsertOne(ctx, set)
+	return err
+}
+
+func ListQueryRules(ctx context.Context, queryRuleCollection *mongo.Collection) ([]models.QueryRule, error) {
+	cursor, err := queryRuleCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rules := []models.QueryRule{}
+	if err = cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func CreateQueryRule(ctx context.Context, queryRuleCollection *mongo.Collection, rule *models.QueryRule) error {
+	if rule.Action == "redirect" && rule.Category_Id == nil {
+		return ErrInvalidQueryRule
+	}
+	if rule.Action != "redirect" && len(rule.Product_Ids) == 0 {
+		return ErrInvalidQueryRule
+	}
+
+	rule.ID = primitive.NewObjectID()
+	rule.Created_At = time.Now()
+
+	_, err := queryRuleCollection.InsertOne(ctx, rule)
+	return err
+}
+
+// DeleteByID removes one document by its hex ID, returning notFound if
+// nothing matched.
+func DeleteByID(ctx context.Context, collection *mongo.Collection, id string, notFound error) error {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return notFound
+	}
+
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": objID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return notFound
+	}
+
+	return nil
+}
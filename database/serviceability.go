@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrPincodeNotServiceable = errors.New("we do not deliver to this pincode")
+	ErrCODNotAvailable       = errors.New("cash on delivery is not available for this pincode")
+	ErrNoDeliveryAddress     = errors.New("no delivery address found for user")
+	ErrInvalidServiceability = errors.New("invalid serviceability data")
+)
+
+// serviceabilityColumns is the header expected on serviceability CSV imports.
+var serviceabilityColumns = []string{"pincode", "deliverable", "cod_allowed", "transit_days"}
+
+func CheckPincode(ctx context.Context, serviceabilityCollection *mongo.Collection, pincode string) (models.Serviceability, error) {
+	var entry models.Serviceability
+
+	err := serviceabilityCollection.FindOne(ctx, bson.M{"pincode": strings.TrimSpace(pincode)}).Decode(&entry)
+	if err == mongo.ErrNoDocuments {
+		return entry, ErrPincodeNotServiceable
+	}
+	if err != nil {
+		return entry, err
+	}
+
+	if !entry.Deliverable {
+		return entry, ErrPincodeNotServiceable
+	}
+
+	return entry, nil
+}
+
+// ValidateDelivery blocks checkout to pincodes we don't serve, and COD where
+// the pincode doesn't allow it.
+func ValidateDelivery(ctx context.Context, serviceabilityCollection *mongo.Collection, address models.Address, cod bool) (models.Serviceability, error) {
+	if address.Pincode == nil {
+		return models.Serviceability{}, ErrPincodeNotServiceable
+	}
+
+	entry, err := CheckPincode(ctx, serviceabilityCollection, *address.Pincode)
+	if err != nil {
+		return entry, err
+	}
+
+	if cod && !entry.COD_Allowed {
+		return entry, ErrCODNotAvailable
+	}
+
+	return entry, nil
+}
+
+// EstimateDeliveryDate adds the transit days to from, skipping Sundays.
+func EstimateDeliveryDate(from time.Time, transitDays int) time.Time {
+	date := from
+	for transitDays > 0 {
+		date = date.AddDate(0, 0, 1)
+		if date.Weekday() != time.Sunday {
+			transitDays--
+		}
+	}
+	return date
+}
+
+// UserAddress returns the address with addressID from the user's
+// Address_Details, or the first saved address when addressID is empty.
+func UserAddress(user models.User, addressID string) (models.Address, error) {
+	if len(user.Address_Details) == 0 {
+		return models.Address{}, ErrNoDeliveryAddress
+	}
+
+	if addressID == "" {
+		return user.Address_Details[0], nil
+	}
+
+	for _, address := range user.Address_Details {
+		if address.ID.Hex() == addressID {
+			return address, nil
+		}
+	}
+
+	return models.Address{}, ErrNoDeliveryAddress
+}
+
+// ImportServiceabilityCSV upserts one serviceability entry per CSV row, keyed
+// by pincode. Rows that fail validation are skipped and reported back.
+func ImportServiceabilityCSV(ctx context.Context, serviceabilityCollection *mongo.Collection, r io.Reader) (int, []models.ImportRowError, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return 0, nil, ErrInvalidServiceability
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range serviceabilityColumns {
+		if _, ok := columns[name]; !ok {
+			return 0, nil, fmt.Errorf("%w: missing column %q", ErrInvalidServiceability, name)
+		}
+	}
+
+	validate := validator.New()
+	imported := 0
+	var rowErrors []models.ImportRowError
+
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: row, Error: err.Error()})
+			continue
+		}
+
+		entry, err := parseServiceabilityRecord(record, columns)
+		if err == nil {
+			err = validate.Struct(entry)
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, models.ImportRowError{Row: row, Error: err.Error()})
+			continue
+		}
+
+		update := bson.M{
+			"$set": bson.M{
+				"deliverable":  entry.Deliverable,
+				"cod_allowed":  entry.COD_Allowed,
+				"transit_days": entry.Transit_Days,
+				"updated_at":   time.Now(),
+			},
+		}
+
+		_, err = serviceabilityCollection.UpdateOne(ctx, bson.M{"pincode": entry.Pincode}, update, options.Update().SetUpsert(true))
+		if err != nil {
+			return imported, rowErrors, err
+		}
+		imported++
+	}
+
+	return imported, rowErrors, nil
+}
+
+func parseServiceabilityRecord(record []string, columns map[string]int) (models.Serviceability, error) {
+	field := func(name string) string {
+		i := columns[name]
+		if i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	deliverable, err := strconv.ParseBool(field("deliverable"))
+	if err != nil {
+		return models.Serviceability{}, fmt.Errorf("invalid deliverable value %q", field("deliverable"))
+	}
+
+	codAllowed, err := strconv.ParseBool(field("cod_allowed"))
+	if err != nil {
+		return models.Serviceability{}, fmt.Errorf("invalid cod_allowed value %q", field("cod_allowed"))
+	}
+
+	transitDays, err := strconv.Atoi(field("transit_days"))
+	if err != nil {
+		return models.Serviceability{}, fmt.Errorf("invalid transit_days value %q", field("transit_days"))
+	}
+
+	return models.Serviceability{
+		Pincode:      field("pincode"),
+		Deliverable:  deliverable,
+		COD_Allowed:  codAllowed,
+		Transit_Days: transitDays,
+	}, nil
+}
@@ -0,0 +1,27 @@
+package database
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Slugify lowercases s and collapses every run of non-alphanumeric
+// characters into a single hyphen.
+func Slugify(s string) string {
+	var b strings.Builder
+	hyphen := false
+
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			hyphen = false
+			continue
+		}
+		if !hyphen && b.Len() > 0 {
+			b.WriteByte('-')
+			hyphen = true
+		}
+	}
+
+	return strings.TrimSuffix(b.String(), "-")
+}
@@ -0,0 +1,33 @@
+package database
+
+import (
+	"context"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// User types, carried in the user's token. Admins are promoted by setting
+// user_type on their user document; sign up always creates plain users.
+const (
+	UserTypeUser  = "USER"
+	UserTypeAdmin = "ADMIN"
+)
+
+func GetUser(ctx context.Context, userCollection *mongo.Collection, userID string) (models.User, error) {
+	var user models.User
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return user, ErrUserIdIsNotValid
+	}
+
+	err = userCollection.FindOne(ctx, bson.M{"_id": userObjID}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return user, ErrUserIdIsNotValid
+	}
+
+	return user, err
+}
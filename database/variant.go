@@ -0,0 +1,240 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvalidVariants = errors.New("invalid product variants")
+	ErrSKURequired     = errors.New("sku is required for products with variants")
+	ErrCantFindVariant = errors.New("can't find the product variant")
+	ErrOutOfStock      = errors.New("product variant is out of stock")
+	ErrDuplicateSKU    = errors.New("sku is already in use")
+)
+
+// ValidateVariants checks that every variant sets exactly the product's option
+// dimensions to one of their allowed values, and that SKUs and option
+// combinations are unique within the product.
+func ValidateVariants(product models.Product) error {
+	if len(product.Variants) == 0 {
+		return nil
+	}
+
+	allowed := make(map[string]map[string]bool, len(product.Options))
+	for _, option := range product.Options {
+		if _, ok := allowed[option.Name]; ok {
+			return fmt.Errorf("%w: duplicate option %q", ErrInvalidVariants, option.Name)
+		}
+		allowed[option.Name] = make(map[string]bool, len(option.Values))
+		for _, value := range option.Values {
+			allowed[option.Name][value] = true
+		}
+	}
+
+	skus := make(map[string]bool, len(product.Variants))
+	combinations := make(map[string]bool, len(product.Variants))
+
+	for _, variant := range product.Variants {
+		if skus[variant.SKU] {
+			return fmt.Errorf("%w: duplicate sku %q", ErrInvalidVariants, variant.SKU)
+		}
+		skus[variant.SKU] = true
+
+		if len(variant.Options) != len(allowed) {
+			return fmt.Errorf("%w: sku %q must set every option", ErrInvalidVariants, variant.SKU)
+		}
+
+		key := ""
+		for _, option := range product.Options {
+			value, ok := variant.Options[option.Name]
+			if !ok || !allowed[option.Name][value] {
+				return fmt.Errorf("%w: sku %q has invalid value for %q", ErrInvalidVariants, variant.SKU, option.Name)
+			}
+			key += option.Name + "=" + value + ";"
+		}
+
+		if combinations[key] {
+			return fmt.Errorf("%w: sku %q repeats another variant's options", ErrInvalidVariants, variant.SKU)
+		}
+		combinations[key] = true
+	}
+
+	return nil
+}
+
+func FindVariant(product models.Product, sku string) (models.Variant, bool) {
+	for _, variant := range product.Variants {
+		if variant.SKU == sku {
+			return variant, true
+		}
+	}
+	return models.Variant{}, false
+}
+
+// SKUsInUse reports whether any of skus already belong to a product other
+// than exclude.
+func SKUsInUse(ctx context.Context, productCollection *mongo.Collection, skus []string, exclude primitive.ObjectID) (bool, error) {
+	if len(skus) == 0 {
+		return false, nil
+	}
+
+	count, err := productCollection.CountDocuments(ctx, bson.M{
+		"_id": bson.M{"$ne": exclude},
+		"$or": bson.A{
+			bson.M{"sku": bson.M{"$in": skus}},
+			bson.M{"variants.sku": bson.M{"$in": skus}},
+		},
+	})
+	return count > 0, err
+}
+
+// VariantCartLine builds the cart line for a specific SKU of product, using
+// the variant's price and image.
+func VariantCartLine(product models.Product, variant models.Variant) models.ProductUser {
+	sku := variant.SKU
+	image := product.Image
+	if variant.Image != nil {
+		image = variant.Image
+	}
+
+	return models.ProductUser{
+		ID:           product.ID,
+		Product_Name: product.Product_Name,
+		Price:        variant.Price,
+		Rating:       product.Rating,
+		Image:        image,
+		SKU:          &sku,
+		Options:      variant.Options,
+	}
+}
+
+func AddVariantToCart(ctx context.Context, productCollection, userCollection *mongo.Collection, productID primitive.ObjectID, sku string, userID string) error {
+	var product models.Product
+	err := productCollection.FindOne(ctx, bson.M{"_id": productID}).Decode(&product)
+	if err == mongo.ErrNoDocuments {
+		return ErrCantFindProduct
+	}
+	if err != nil {
+		return err
+	}
+
+	if !IsLive(product, time.Now()) {
+		return ErrProductNotAvailable
+	}
+
+	variant, ok := FindVariant(product, sku)
+	if !ok {
+		return ErrCantFindVariant
+	}
+	if variant.Stock == nil || *variant.Stock <= 0 {
+		return ErrOutOfStock
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return ErrUserIdIsNotValid
+	}
+
+	update := bson.M{"$push": bson.M{"user_cart": VariantCartLine(product, variant)}}
+	result, err := userCollection.UpdateOne(ctx, bson.M{"_id": userObjID}, update)
+	if err != nil {
+		return ErrCantUpdateUser
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserIdIsNotValid
+	}
+
+	return nil
+}
+
+func RemoveVariantFromCart(ctx context.Context, userCollection *mongo.Collection, productID primitive.ObjectID, sku string, userID string) error {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return ErrUserIdIsNotValid
+	}
+
+	update := bson.M{"$pull": bson.M{"user_cart": bson.M{"_id": productID, "sku": sku}}}
+	_, err = userCollection.UpdateOne(ctx, bson.M{"_id": userObjID}, update)
+	if err != nil {
+		return ErrCantRemoveItemCart
+	}
+
+	return nil
+}
+
+// InstantBuyItem places a single-line order for line, used when it has stock
+// to account for: a SKU, or a bundle broken down into fulfilment.
+func InstantBuyItem(ctx context.Context, userCollection *mongo.Collection, userID string, line models.ProductUser, fulfilment []models.FulfilmentItem, payment models.Payment, shipping models.Address) error {
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return ErrUserIdIsNotValid
+	}
+
+	order := models.Order{
+		ID:               primitive.NewObjectID(),
+		Order_Cart:       []models.ProductUser{line},
+		Ordered_At:       time.Now(),
+		Price:            line.Price,
+		Payment_Method:   payment,
+		Shipping_Address: &shipping,
+		Fulfilment_Items: fulfilment,
+	}
+
+	result, err := userCollection.UpdateOne(ctx, bson.M{"_id": userObjID}, bson.M{"$push": bson.M{"order_status": order}})
+	if err != nil {
+		return ErrCantBuyCartItem
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserIdIsNotValid
+	}
+	return nil
+}
+
+// ReserveVariantStock takes the stock for every SKU in items, releasing what
+// it already took if any SKU has run out.
+func ReserveVariantStock(ctx context.Context, productCollection *mongo.Collection, items []models.FulfilmentItem) error {
+	var reserved []models.FulfilmentItem
+
+	for _, item := range items {
+		if item.SKU == nil {
+			continue
+		}
+
+		filter := bson.M{
+			"_id":      item.Product_Id,
+			"variants": bson.M{"$elemMatch": bson.M{"sku": *item.SKU, "stock": bson.M{"$gte": item.Quantity}}},
+		}
+		result, err := productCollection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"variants.$.stock": -item.Quantity}})
+		if err == nil && result.MatchedCount == 0 {
+			err = ErrOutOfStock
+		}
+		if err != nil {
+			ReleaseVariantStock(ctx, productCollection, reserved)
+			return err
+		}
+
+		reserved = append(reserved, item)
+	}
+
+	return nil
+}
+
+func ReleaseVariantStock(ctx context.Context, productCollection *mongo.Collection, items []models.FulfilmentItem) {
+	for _, item := range items {
+		if item.SKU == nil {
+			continue
+		}
+		productCollection.UpdateOne(ctx,
+			bson.M{"_id": item.Product_Id, "variants.sku": *item.SKU},
+			bson.M{"$inc": bson.M{"variants.$.stock": item.Quantity}},
+		)
+	}
+}
@@ -0,0 +1,262 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrCantFindWishlist     = errors.New("can't find the wishlist")
+	ErrCantFindWishlistItem = errors.New("product isn't in the wishlist")
+	ErrTooManyWishlists     = errors.New("too many wishlists")
+	ErrWishlistFull         = errors.New("wishlist is full")
+)
+
+const (
+	MaxWishlists     = 20
+	MaxWishlistItems = 200
+)
+
+// WishlistProduct is a wishlist item with its product, which is nil once the
+// product is no longer on sale.
+type WishlistProduct struct {
+	models.WishlistItem
+	Product   *models.Product `json:"product"`
+	Available bool            `json:"available"`
+}
+
+func newShareToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func CreateWishlist(ctx context.Context, wishlistCollection *mongo.Collection, wishlist *models.Wishlist) error {
+	count, err := wishlistCollection.CountDocuments(ctx, bson.M{"user_id": wishlist.User_Id})
+	if err != nil {
+		return err
+	}
+	if count >= MaxWishlists {
+		return ErrTooManyWishlists
+	}
+
+	wishlist.ID = primitive.NewObjectID()
+	wishlist.Items = []models.WishlistItem{}
+	wishlist.Share_Token = ""
+	if wishlist.Public {
+		if wishlist.Share_Token, err = newShareToken(); err != nil {
+			return err
+		}
+	}
+	wishlist.Created_At = time.Now()
+	wishlist.Updated_At = wishlist.Created_At
+
+	_, err = wishlistCollection.InsertOne(ctx, wishlist)
+	return err
+}
+
+func ListWishlists(ctx context.Context, wishlistCollection *mongo.Collection, userID string) ([]models.Wishlist, error) {
+	cursor, err := wishlistCollection.Find(ctx, bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	wishlists := []models.Wishlist{}
+	if err = cursor.All(ctx, &wishlists); err != nil {
+		return nil, err
+	}
+	return wishlists, nil
+}
+
+func GetWishlist(ctx context.Context, wishlistCollection *mongo.Collection, userID string, wishlistID primitive.ObjectID) (models.Wishlist, error) {
+	var wishlist models.Wishlist
+	err := wishlistCollection.FindOne(ctx, bson.M{"_id": wishlistID, "user_id": userID}).Decode(&wishlist)
+	if err == mongo.ErrNoDocuments {
+		return wishlist, ErrCantFindWishlist
+	}
+	return wishlist, err
+}
+
+// GetSharedWishlist finds a public wishlist by its share token.
+func GetSharedWishlist(ctx context.Context, wishlistCollection *mongo.Collection, token string) (models.Wishlist, error) {
+	var wishlist models.Wishlist
+	err := wishlistCollection.FindOne(ctx, bson.M{"share_token": token, "public": true}).Decode(&wishlist)
+	if err == mongo.ErrNoDocuments {
+		return wishlist, ErrCantFindWishlist
+	}
+	return wishlist, err
+}
+
+// UpdateWishlist renames a wishlist or changes whether it is shared. Making
+// it private revokes the share link, so sharing it again gives a new one.
+func UpdateWishlist(ctx context.Context, wishlistCollection *mongo.Collection, userID string, wishlistID primitive.ObjectID, name *string, public *bool) (models.Wishlist, error) {
+	wishlist, err := GetWishlist(ctx, wishlistCollection, userID, wishlistID)
+	if err != nil {
+		return wishlist, err
+	}
+
+	set := bson.M{"updated_at": time.Now()}
+	update := bson.M{"$set": set}
+	if name != nil {
+		set["name"] = *name
+	}
+	if public != nil {
+		set["public"] = *public
+		switch {
+		case *public && wishlist.Share_Token == "":
+			token, err := newShareToken()
+			if err != nil {
+				return wishlist, err
+			}
+			set["share_token"] = token
+		case !*public:
+			update["$unset"] = bson.M{"share_token": ""}
+		}
+	}
+
+	var updated models.Wishlist
+	err = wishlistCollection.FindOneAndUpdate(ctx, bson.M{"_id": wishlistID, "user_id": userID}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err == mongo.ErrNoDocuments {
+		return updated, ErrCantFindWishlist
+	}
+	return updated, err
+}
+
+func DeleteWishlist(ctx context.Context, wishlistCollection *mongo.Collection, userID string, wishlistID primitive.ObjectID) error {
+	result, err := wishlistCollection.DeleteOne(ctx, bson.M{"_id": wishlistID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrCantFindWishlist
+	}
+	return nil
+}
+
+// AddWishlistItem adds a product, or one SKU of it, to a wishlist. Adding
+// something already there does nothing.
+func AddWishlistItem(ctx context.Context, wishlistCollection, productCollection *mongo.Collection, userID string, wishlistID, productID primitive.ObjectID, sku *string) error {
+	product, err := GetProduct(ctx, productCollection, productID)
+	if err != nil {
+		return err
+	}
+	if sku != nil {
+		if _, ok := FindVariant(product, *sku); !ok {
+			return ErrCantFindVariant
+		}
+	}
+
+	item := models.WishlistItem{Product_Id: productID, SKU: sku, Added_At: time.Now()}
+	filter := bson.M{
+		"_id":     wishlistID,
+		"user_id": userID,
+		"items":   bson.M{"$not": bson.M{"$elemMatch": bson.M{"product_id": productID, "sku": sku}}},
+		"items." + strconv.Itoa(MaxWishlistItems-1): bson.M{"$exists": false},
+	}
+	update := bson.M{
+		"$push": bson.M{"items": item},
+		"$set":  bson.M{"updated_at": item.Added_At},
+	}
+
+	result, err := wishlistCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount > 0 {
+		return nil
+	}
+
+	wishlist, err := GetWishlist(ctx, wishlistCollection, userID, wishlistID)
+	if err != nil {
+		return err
+	}
+	for _, existing := range wishlist.Items {
+		if existing.Product_Id == productID && sameString(existing.SKU, sku) {
+			return nil
+		}
+	}
+	return ErrWishlistFull
+}
+
+func RemoveWishlistItem(ctx context.Context, wishlistCollection *mongo.Collection, userID string, wishlistID, productID primitive.ObjectID, sku *string) error {
+	match := bson.M{"product_id": productID, "sku": sku}
+	filter := bson.M{"_id": wishlistID, "user_id": userID, "items": bson.M{"$elemMatch": match}}
+	update := bson.M{
+		"$pull": bson.M{"items": match},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+
+	result, err := wishlistCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount > 0 {
+		return nil
+	}
+
+	if _, err := GetWishlist(ctx, wishlistCollection, userID, wishlistID); err != nil {
+		return err
+	}
+	return ErrCantFindWishlistItem
+}
+
+// WishlistProducts pairs a wishlist's items with their products, marking
+// those no longer on sale as unavailable.
+func WishlistProducts(ctx context.Context, productCollection *mongo.Collection, wishlist models.Wishlist) ([]WishlistProduct, error) {
+	items := make([]WishlistProduct, 0, len(wishlist.Items))
+	if len(wishlist.Items) == 0 {
+		return items, nil
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(wishlist.Items))
+	for _, item := range wishlist.Items {
+		ids = append(ids, item.Product_Id)
+	}
+
+	filter := LiveProductFilter(time.Now())
+	filter["_id"] = bson.M{"$in": ids}
+	cursor, err := productCollection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var products []models.Product
+	if err = cursor.All(ctx, &products); err != nil {
+		return nil, ErrCantDecodeProducts
+	}
+
+	byID := make(map[primitive.ObjectID]models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+
+	for _, item := range wishlist.Items {
+		entry := WishlistProduct{WishlistItem: item}
+		if product, ok := byID[item.Product_Id]; ok {
+			entry.Product = &product
+			entry.Available = true
+			if item.SKU != nil {
+				_, entry.Available = FindVariant(product, *item.SKU)
+			}
+		}
+		items = append(items, entry)
+	}
+
+	return items, nil
+}
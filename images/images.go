@@ -0,0 +1,153 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+var (
+	ErrUnsupportedType = errors.New("image must be a JPEG, PNG or GIF")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+)
+
+// maxPixels guards against images that are small on disk but huge decoded.
+const maxPixels = 40_000_000
+
+// Thumbnail sizes, as the longest side in pixels.
+var ThumbnailSizes = []struct {
+	Name string
+	Size int
+}{
+	{"small", 150},
+	{"medium", 400},
+}
+
+type Encoded struct {
+	Name         string
+	Content_Type string
+	Ext          string
+	Width        int
+	Height       int
+	Data         []byte
+}
+
+// Upload is a validated image and its thumbnails.
+type Upload struct {
+	Original   Encoded
+	Thumbnails []Encoded
+}
+
+// Process checks that data is a supported image of sane dimensions and
+// renders its thumbnails. JPEGs get JPEG thumbnails; PNGs and GIFs get PNG
+// ones so transparency survives.
+func Process(data []byte) (*Upload, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := map[string]string{
+		"image/jpeg": ".jpg",
+		"image/png":  ".png",
+		"image/gif":  ".gif",
+	}[contentType]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+
+	upload := &Upload{Original: Encoded{
+		Name:         "original",
+		Content_Type: contentType,
+		Ext:          ext,
+		Width:        config.Width,
+		Height:       config.Height,
+		Data:         data,
+	}}
+
+	for _, size := range ThumbnailSizes {
+		thumb := Fit(img, size.Size)
+
+		var buf bytes.Buffer
+		encoded := Encoded{Name: size.Name, Width: thumb.Bounds().Dx(), Height: thumb.Bounds().Dy()}
+		if contentType == "image/jpeg" {
+			encoded.Content_Type, encoded.Ext = "image/jpeg", ".jpg"
+			err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+		} else {
+			encoded.Content_Type, encoded.Ext = "image/png", ".png"
+			err = png.Encode(&buf, thumb)
+		}
+		if err != nil {
+			return nil, err
+		}
+		encoded.Data = buf.Bytes()
+		upload.Thumbnails = append(upload.Thumbnails, encoded)
+	}
+
+	return upload, nil
+}
+
+// Fit scales img down so its longest side is at most size, averaging the
+// source pixels under each output pixel. Smaller images are returned as is.
+func Fit(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	tw, th := size, h*size/w
+	if h > w {
+		tw, th = w*size/h, size
+	}
+	tw, th = max(tw, 1), max(th, 1)
+
+	src := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0, y1 := y*h/th, max((y+1)*h/th, y*h/th+1)
+		for x := 0; x < tw; x++ {
+			x0, x1 := x*w/tw, max((x+1)*w/tw, x*w/tw+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					alpha := uint64(p[3])
+					r += uint64(p[0]) * alpha
+					g += uint64(p[1]) * alpha
+					b += uint64(p[2]) * alpha
+					a += alpha
+					n++
+				}
+			}
+
+			i := y*dst.Stride + x*4
+			if a > 0 {
+				dst.Pix[i] = uint8(r / a)
+				dst.Pix[i+1] = uint8(g / a)
+				dst.Pix[i+2] = uint8(b / a)
+			}
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
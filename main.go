@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
+	"log"
 	"os"
+	"time"
 
 	"github.com/djwhocodes/ecom_cart_golang/controllers"
 	"github.com/djwhocodes/ecom_cart_golang/database"
 	"github.com/djwhocodes/ecom_cart_golang/middleware"
+	"github.com/djwhocodes/ecom_cart_golang/notify"
 	"github.com/djwhocodes/ecom_cart_golang/routes"
+	"github.com/djwhocodes/ecom_cart_golang/storage"
 	"github.com/gin-gonic/gin"
 )
 
@@ -17,8 +22,58 @@ func main() {
 		port = "8080"
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := database.EnsureProductTextIndex(ctx, controllers.ProductCollection); err != nil {
+		log.Println("failed to create product text index:", err)
+	}
+	if err := database.EnsureProductSlugIndexes(ctx, controllers.ProductCollection); err != nil {
+		log.Println("failed to create product slug indexes:", err)
+	}
+	if err := controllers.EnsureProductHistoryIndexes(ctx); err != nil {
+		log.Println("failed to create product history indexes:", err)
+	}
+	if err := controllers.EnsureAlertIndexes(ctx); err != nil {
+		log.Println("failed to create alert indexes:", err)
+	}
+	if err := controllers.EnsureReviewIndexes(ctx); err != nil {
+		log.Println("failed to create review indexes:", err)
+	}
+	if err := controllers.FailInterruptedImports(ctx); err != nil {
+		log.Println("failed to clean up interrupted imports:", err)
+	}
+	cancel()
+
+	// Backfills bring products stored before a field existed up to date.
+	// They may touch the whole catalogue, so they get longer.
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Minute)
+	if filled, err := database.BackfillProductSlugs(ctx, controllers.ProductCollection); err != nil {
+		log.Println("failed to backfill product slugs:", err)
+	} else if filled > 0 {
+		log.Println("backfilled product slugs:", filled)
+	}
+	if filled, err := database.BackfillRatingAverages(ctx, controllers.ProductCollection); err != nil {
+		log.Println("failed to backfill product ratings:", err)
+	} else if filled > 0 {
+		log.Println("backfilled product ratings:", filled)
+	}
+	cancel()
+
+	go controllers.SyncSearchIndexes(5 * time.Minute)
+
+	imageDir := os.Getenv("IMAGE_DIR")
+	if imageDir == "" {
+		imageDir = "uploads"
+	}
+	controllers.ImageStorage = storage.NewLocal(imageDir)
+
+	if notifyFile := os.Getenv("NOTIFY_FILE"); notifyFile != "" {
+		controllers.AlertNotifier = notify.NewFile(notifyFile)
+	}
+
 	app := controllers.NewApplication(database.ProductData(database.Client, "Products"), database.UserData(database.Client, "User"))
 
+	go app.SyncRelatedProducts(time.Hour)
+
 	router := gin.Default()
 
 	routes.UserRoutes(router)
@@ -29,4 +84,73 @@ func main() {
 	router.GET("/removeitem", app.RemoveItem())
 	router.GET("/cartcheckout", app.BuyFromCart())
 	router.GET("instantbuy", app.InstantBuy())
+	router.POST("/saveforlater", app.SaveForLater())
+	router.POST("/movetocart", app.MoveToCart())
+	router.GET("/savedforlater", app.SavedForLater())
+	router.DELETE("/savedforlater", app.RemoveSavedItem())
+
+	router.POST("/wishlists", controllers.CreateWishlist())
+	router.GET("/wishlists", controllers.ListWishlists())
+	router.GET("/wishlists/:id", controllers.GetWishlist())
+	router.PUT("/wishlists/:id", controllers.UpdateWishlist())
+	router.DELETE("/wishlists/:id", controllers.DeleteWishlist())
+	router.POST("/wishlists/:id/items", controllers.AddWishlistItem())
+	router.DELETE("/wishlists/:id/items/:product_id", controllers.RemoveWishlistItem())
+
+	router.POST("/alerts", controllers.CreateAlert())
+	router.GET("/alerts", controllers.ListAlerts())
+	router.DELETE("/alerts/:id", controllers.DeleteAlert())
+
+	router.POST("/checkout", app.StartCheckout())
+	router.GET("/checkout/:id", app.GetCheckout())
+	router.PUT("/checkout/:id/address", app.SetCheckoutAddress())
+	router.PUT("/checkout/:id/shipping", app.SetCheckoutShipping())
+	router.PUT("/checkout/:id/coupon", app.SetCheckoutCoupon())
+	router.PUT("/checkout/:id/payment", app.SetCheckoutPayment())
+	router.POST("/checkout/:id/confirm", app.ConfirmCheckout())
+
+	router.POST("/products/:id/reviews", app.AddReview())
+	router.POST("/reviews/:id/helpful", controllers.VoteReviewHelpful())
+	router.POST("/products/:id/questions", app.AskQuestion())
+	router.POST("/questions/:id/answers", app.AnswerQuestion())
+	router.POST("/questions/:id/upvote", controllers.UpvoteQuestion())
+	router.POST("/questions/:id/answers/:answer_id/upvote", controllers.UpvoteAnswer())
+	router.POST("/products/:id/views", controllers.RecordProductView())
+	router.GET("/recentlyviewed", controllers.RecentlyViewed())
+	router.DELETE("/recentlyviewed", controllers.ClearRecentlyViewed())
+
+	admin := router.Group("/admin", middleware.AdminOnly())
+	admin.POST("/serviceability/import", controllers.ImportServiceability())
+	admin.GET("/cod/rules", controllers.GetCODRules())
+	admin.PUT("/cod/rules", controllers.UpdateCODRules())
+	admin.POST("/cod/refusals", app.RecordCODRefusal())
+	admin.POST("/coupons", controllers.AddCoupon())
+	admin.POST("/categories", controllers.AddCategory())
+	admin.PUT("/categories/:id", controllers.EditCategory())
+	admin.POST("/products/import", controllers.ImportProducts())
+	admin.GET("/products/import/:id", controllers.GetImportJob())
+	admin.GET("/products/import/:id/errors", controllers.ImportErrorReport())
+	admin.GET("/products/export", controllers.ExportProducts())
+	admin.PUT("/products/:id", controllers.UpdateProduct())
+	admin.DELETE("/products/:id", controllers.DeleteProduct())
+	admin.PUT("/products/:id/status", controllers.SetProductStatus())
+	admin.POST("/products/:id/images", controllers.UploadProductImage())
+	admin.PUT("/products/:id/images/order", controllers.ReorderProductImages())
+	admin.DELETE("/products/:id/images/:image_id", controllers.DeleteProductImage())
+	admin.GET("/products/:id/history", controllers.ProductHistory())
+	admin.POST("/products/:id/revert/:version", controllers.RevertProduct())
+	admin.PUT("/products/:id/categories", controllers.AssignProductCategories())
+	admin.GET("/search/synonyms", controllers.ListSynonyms())
+	admin.POST("/search/synonyms", controllers.AddSynonyms())
+	admin.DELETE("/search/synonyms/:id", controllers.DeleteSynonyms())
+	admin.GET("/search/rules", controllers.ListQueryRules())
+	admin.POST("/search/rules", controllers.AddQueryRule())
+	admin.DELETE("/search/rules/:id", controllers.DeleteQueryRule())
+	admin.GET("/search/reports", controllers.SearchReport())
+	admin.GET("/reviews", controllers.ListReviewsForModeration())
+	admin.PUT("/reviews/:id", controllers.ModerateReview())
+	admin.GET("/questions", controllers.ListQuestionsForModeration())
+	admin.PUT("/questions/:id", controllers.ModerateQuestion())
+	admin.POST("/questions/:id/answers", controllers.AnswerAsSeller())
+	admin.PUT("/questions/:id/answers/:answer_id", controllers.ModerateAnswer())
 }
@@ -0,0 +1,184 @@
+// Package markdown renders the small Markdown subset used in product
+// descriptions. Input HTML is always escaped, so the output is safe to embed
+// in a page as is.
+package markdown
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	headingPattern = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	bulletPattern  = regexp.MustCompile(`^[-*+]\s+(.*)$`)
+	orderedPattern = regexp.MustCompile(`^\d{1,9}[.)]\s+(.*)$`)
+	rulePattern    = regexp.MustCompile(`^(?:-{3,}|\*{3,}|_{3,})$`)
+	linkPattern    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	strongPattern  = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*|__(\S(?:.*?\S)?)__`)
+	emPattern      = regexp.MustCompile(`\*(\S(?:.*?\S)?)\*|\b_(\S(?:.*?\S)?)_\b`)
+)
+
+// Render converts Markdown to HTML. It supports headings, paragraphs,
+// bullet and numbered lists, block quotes, rules, fenced code, inline code,
+// bold, italics and links to http, https, mailto or relative URLs.
+func Render(src string) string {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+
+	var out strings.Builder
+	var paragraph []string
+	var list []string
+	listTag := ""
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			out.WriteString("<p>" + inline(strings.Join(paragraph, "\n")) + "</p>\n")
+			paragraph = nil
+		}
+	}
+	flushList := func() {
+		if len(list) > 0 {
+			out.WriteString("<" + listTag + ">\n")
+			for _, item := range list {
+				out.WriteString("<li>" + inline(item) + "</li>\n")
+			}
+			out.WriteString("</" + listTag + ">\n")
+			list = nil
+		}
+	}
+	flush := func() {
+		flushParagraph()
+		flushList()
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], " \t")
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			flush()
+
+		case strings.HasPrefix(trimmed, "```"):
+			flush()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+			out.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+
+		case headingPattern.MatchString(trimmed):
+			flush()
+			m := headingPattern.FindStringSubmatch(trimmed)
+			level := strconv.Itoa(len(m[1]))
+			out.WriteString("<h" + level + ">" + inline(m[2]) + "</h" + level + ">\n")
+
+		case rulePattern.MatchString(trimmed):
+			flush()
+			out.WriteString("<hr>\n")
+
+		case bulletPattern.MatchString(trimmed), orderedPattern.MatchString(trimmed):
+			flushParagraph()
+			tag, pattern := "ul", bulletPattern
+			if !bulletPattern.MatchString(trimmed) {
+				tag, pattern = "ol", orderedPattern
+			}
+			if tag != listTag {
+				flushList()
+				listTag = tag
+			}
+			list = append(list, pattern.FindStringSubmatch(trimmed)[1])
+
+		case strings.HasPrefix(trimmed, ">"):
+			flush()
+			var quote []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				quote = append(quote, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")))
+			}
+			i--
+			out.WriteString("<blockquote><p>" + inline(strings.Join(quote, "\n")) + "</p></blockquote>\n")
+
+		default:
+			if len(list) > 0 && (strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t")) {
+				list[len(list)-1] += " " + trimmed
+				continue
+			}
+			flushList()
+			paragraph = append(paragraph, trimmed)
+		}
+	}
+	flush()
+
+	return out.String()
+}
+
+// inline renders the spans within a block. Code spans are left alone;
+// everything else is escaped before any tags are added.
+func inline(text string) string {
+	var out strings.Builder
+	parts := strings.Split(text, "`")
+	for i, part := range parts {
+		switch {
+		case i%2 == 1 && i < len(parts)-1:
+			out.WriteString("<code>" + html.EscapeString(part) + "</code>")
+		case i%2 == 1:
+			out.WriteString("`" + emphasis(part))
+		default:
+			out.WriteString(emphasis(part))
+		}
+	}
+	return out.String()
+}
+
+func emphasis(text string) string {
+	var links []string
+	text = linkPattern.ReplaceAllStringFunc(text, func(match string) string {
+		m := linkPattern.FindStringSubmatch(match)
+		links = append(links, link(m[1], m[2]))
+		return "\x00" + strconv.Itoa(len(links)-1) + "\x00"
+	})
+
+	text = html.EscapeString(text)
+	text = strongPattern.ReplaceAllString(text, "<strong>$1$2</strong>")
+	text = emPattern.ReplaceAllString(text, "<em>$1$2</em>")
+
+	for i, rendered := range links {
+		text = strings.Replace(text, "\x00"+strconv.Itoa(i)+"\x00", rendered, 1)
+	}
+	return text
+}
+
+func link(text, url string) string {
+	label := html.EscapeString(text)
+	if !SafeURL(url) {
+		return label
+	}
+	return `<a href="` + html.EscapeString(url) + `" rel="nofollow noopener">` + label + "</a>"
+}
+
+// SafeURL reports whether url is an http, https or mailto link, or a
+// relative one.
+func SafeURL(url string) bool {
+	for _, r := range url {
+		if r <= ' ' || r == 0x7f {
+			return false
+		}
+	}
+
+	lower := strings.ToLower(url)
+	for _, prefix := range []string{"http://", "https://", "mailto:", "/", "#"} {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return !strings.Contains(strings.SplitN(lower, "/", 2)[0], ":")
+}
+
+// PlainText strips the Markdown from src and collapses whitespace, for
+// places such as meta descriptions that need text only.
+func PlainText(src string) string {
+	text := linkPattern.ReplaceAllString(src, "$1")
+	text = strings.NewReplacer("**", "", "__", "", "`", "", "#", "", ">", "", "*", "").Replace(text)
+	return strings.Join(strings.Fields(text), " ")
+}
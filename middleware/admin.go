@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/djwhocodes/ecom_cart_golang/database"
+	"github.com/djwhocodes/ecom_cart_golang/tokens"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminOnly lets a request through only when its token belongs to an admin.
+func AdminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, msg := tokens.ValidateTokens(c.GetHeader("token"))
+		if claims == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": msg})
+			return
+		}
+
+		if userType, _ := claims["user_type"].(string); userType != database.UserTypeAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -19,16 +19,67 @@ type User struct {
 	Updated_At      time.Time          `json:"updated_at" bson:"updated_at"`
 	User_Id         string             `json:"user_id" bson:"user_id"`
 	User_Cart       []ProductUser      `json:"user_cart" bson:"user_cart"`
+	Saved_For_Later []ProductUser      `json:"saved_for_later" bson:"saved_for_later,omitempty"`
 	Address_Details []Address          `json:"address_details" bson:"address_details"`
 	Order_Status    []Order            `json:"order_status" bson:"order_status"`
+	COD_Refusals    int                `json:"cod_refusals" bson:"cod_refusals"`
+	User_Type       string             `json:"-" bson:"user_type"`
 }
 
 type Product struct {
-	ID           primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
-	Product_Name *string            `json:"product_name" bson:"product_name" validate:"required"`
-	Price        *uint32            `json:"price" bson:"price" validate:"required,gte=0"`
-	Rating       *uint8             `json:"rating" bson:"rating,omitempty"`
-	Image        *string            `json:"image" bson:"image,omitempty"`
+	ID              primitive.ObjectID   `json:"_id" bson:"_id,omitempty"`
+	Product_Name    *string              `json:"product_name" bson:"product_name" validate:"required"`
+	SKU             *string              `json:"sku,omitempty" bson:"sku,omitempty"`
+	Slug            string               `json:"slug" bson:"slug,omitempty"`
+	Previous_Slugs  []string             `json:"-" bson:"previous_slugs,omitempty"`
+	Description     *string              `json:"description" bson:"description,omitempty" validate:"omitempty,max=20000"`
+	Specifications  []Specification      `json:"specifications,omitempty" bson:"specifications,omitempty" validate:"omitempty,max=200,dive"`
+	SEO_Title       *string              `json:"seo_title,omitempty" bson:"seo_title,omitempty" validate:"omitempty,max=70"`
+	SEO_Description *string              `json:"seo_description,omitempty" bson:"seo_description,omitempty" validate:"omitempty,max=160"`
+	Price           *uint32              `json:"price" bson:"price" validate:"required,gte=0"`
+	Rating          *uint8               `json:"rating" bson:"rating,omitempty"`
+	Rating_Average  float64              `json:"rating_average" bson:"rating_average"`
+	Rating_Count    int                  `json:"rating_count" bson:"rating_count"`
+	Image           *string              `json:"image" bson:"image,omitempty"`
+	Images          []ProductImage       `json:"images,omitempty" bson:"images,omitempty"`
+	Options         []ProductOption      `json:"options,omitempty" bson:"options,omitempty" validate:"omitempty,dive"`
+	Variants        []Variant            `json:"variants,omitempty" bson:"variants,omitempty" validate:"omitempty,dive"`
+	Bundle          []BundleComponent    `json:"bundle,omitempty" bson:"bundle,omitempty" validate:"omitempty,max=20,dive"`
+	Categories      []primitive.ObjectID `json:"categories,omitempty" bson:"categories,omitempty"`
+	Attributes      map[string]string    `json:"attributes,omitempty" bson:"attributes,omitempty" validate:"omitempty,dive,keys,required,excludesall=.$,endkeys,required"`
+	Search_Text     string               `json:"-" bson:"search_text,omitempty"`
+	Status          string               `json:"status" bson:"status,omitempty" validate:"omitempty,oneof=draft scheduled published archived"`
+	Publish_At      *time.Time           `json:"publish_at,omitempty" bson:"publish_at,omitempty"`
+	Unpublish_At    *time.Time           `json:"unpublish_at,omitempty" bson:"unpublish_at,omitempty"`
+}
+
+// Specification is one row of a product's specification table, such as
+// "Weight: 1.2 kg", optionally under a group heading like "Dimensions".
+type Specification struct {
+	Group string `json:"group,omitempty" bson:"group,omitempty" validate:"max=100"`
+	Name  string `json:"name" bson:"name" validate:"required,max=100"`
+	Value string `json:"value" bson:"value" validate:"required,max=500"`
+}
+
+type ProductOption struct {
+	Name   string   `json:"name" bson:"name" validate:"required"`
+	Values []string `json:"values" bson:"values" validate:"required,min=1,dive,required"`
+}
+
+type Variant struct {
+	SKU     string            `json:"sku" bson:"sku" validate:"required"`
+	Options map[string]string `json:"options" bson:"options"`
+	Price   *uint32           `json:"price" bson:"price" validate:"required,gte=0"`
+	Stock   *int              `json:"stock" bson:"stock" validate:"required,gte=0"`
+	Image   *string           `json:"image" bson:"image,omitempty"`
+}
+
+// BundleComponent is one product in a bundle, with the SKU to take when that
+// product has variants.
+type BundleComponent struct {
+	Product_Id primitive.ObjectID `json:"product_id" bson:"product_id" validate:"required"`
+	SKU        *string            `json:"sku,omitempty" bson:"sku,omitempty"`
+	Quantity   int                `json:"quantity" bson:"quantity" validate:"required,gte=1,lte=100"`
 }
 
 type ProductUser struct {
@@ -37,6 +88,8 @@ type ProductUser struct {
 	Price        *uint32            `json:"price" bson:"price"`
 	Rating       *uint8             `json:"rating" bson:"rating"`
 	Image        *string            `json:"image" bson:"image"`
+	SKU          *string            `json:"sku,omitempty" bson:"sku,omitempty"`
+	Options      map[string]string  `json:"options,omitempty" bson:"options,omitempty"`
 }
 
 type Address struct {
@@ -48,15 +101,277 @@ type Address struct {
 }
 
 type Order struct {
-	ID             primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
-	Order_Cart     []ProductUser      `json:"order_cart" bson:"order_cart"`
-	Ordered_At     time.Time          `json:"ordered_at" bson:"ordered_at"`
-	Price          *uint32            `json:"price" bson:"price"`
-	Discount       *uint8             `json:"discount" bson:"discount,omitempty"`
-	Payment_Method Payment            `json:"payment_method" bson:"payment_method"`
+	ID               primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Order_Cart       []ProductUser      `json:"order_cart" bson:"order_cart"`
+	Ordered_At       time.Time          `json:"ordered_at" bson:"ordered_at"`
+	Price            *uint32            `json:"price" bson:"price"`
+	Discount         *uint8             `json:"discount" bson:"discount,omitempty"`
+	Payment_Method   Payment            `json:"payment_method" bson:"payment_method"`
+	Shipping_Address *Address           `json:"shipping_address" bson:"shipping_address,omitempty"`
+	Billing_Address  *Address           `json:"billing_address" bson:"billing_address,omitempty"`
+	Fulfilment_Items []FulfilmentItem   `json:"fulfilment_items,omitempty" bson:"fulfilment_items,omitempty"`
+}
+
+// FulfilmentItem is what has to be picked and shipped for an order, with
+// bundles broken down into their components.
+type FulfilmentItem struct {
+	Product_Id   primitive.ObjectID  `json:"product_id" bson:"product_id"`
+	Product_Name *string             `json:"product_name" bson:"product_name"`
+	SKU          *string             `json:"sku,omitempty" bson:"sku,omitempty"`
+	Quantity     int                 `json:"quantity" bson:"quantity"`
+	Bundle_Id    *primitive.ObjectID `json:"bundle_id,omitempty" bson:"bundle_id,omitempty"`
 }
 
 type Payment struct {
 	Digital bool `json:"digital" bson:"digital"`
 	COD     bool `json:"cod" bson:"cod"`
 }
+
+type Serviceability struct {
+	ID           primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Pincode      string             `json:"pincode" bson:"pincode" validate:"required,len=6,numeric"`
+	Deliverable  bool               `json:"deliverable" bson:"deliverable"`
+	COD_Allowed  bool               `json:"cod_allowed" bson:"cod_allowed"`
+	Transit_Days int                `json:"transit_days" bson:"transit_days" validate:"gte=0"`
+	Updated_At   time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+type CODRules struct {
+	ID                     primitive.ObjectID   `json:"_id" bson:"_id,omitempty"`
+	Key                    string               `json:"-" bson:"key"`
+	Enabled                bool                 `json:"enabled" bson:"enabled"`
+	Max_Order_Value        *uint32              `json:"max_order_value" bson:"max_order_value,omitempty"`
+	Allowed_Pincodes       []string             `json:"allowed_pincodes" bson:"allowed_pincodes"`
+	Max_Refused_Deliveries *int                 `json:"max_refused_deliveries" bson:"max_refused_deliveries,omitempty" validate:"omitempty,gte=0"`
+	Excluded_Products      []primitive.ObjectID `json:"excluded_products" bson:"excluded_products"`
+	Updated_At             time.Time            `json:"updated_at" bson:"updated_at"`
+}
+
+type Coupon struct {
+	ID              primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Code            *string            `json:"code" bson:"code" validate:"required,min=3,max=32"`
+	Percent_Off     *uint8             `json:"percent_off" bson:"percent_off" validate:"required,gte=1,lte=100"`
+	Min_Order_Value *uint32            `json:"min_order_value" bson:"min_order_value,omitempty"`
+	Expires_At      *time.Time         `json:"expires_at" bson:"expires_at,omitempty"`
+	Active          bool               `json:"active" bson:"active"`
+	Created_At      time.Time          `json:"created_at" bson:"created_at"`
+}
+
+type CheckoutSession struct {
+	ID                 primitive.ObjectID  `json:"_id" bson:"_id,omitempty"`
+	User_Id            string              `json:"user_id" bson:"user_id"`
+	Status             string              `json:"status" bson:"status"`
+	Items              []ProductUser       `json:"items" bson:"items"`
+	Address_Id         *primitive.ObjectID `json:"address_id" bson:"address_id,omitempty"`
+	Billing_Address_Id *primitive.ObjectID `json:"billing_address_id" bson:"billing_address_id,omitempty"`
+	Shipping_Method    string              `json:"shipping_method" bson:"shipping_method"`
+	Coupon_Code        *string             `json:"coupon_code" bson:"coupon_code,omitempty"`
+	Payment_Method     *Payment            `json:"payment_method" bson:"payment_method,omitempty"`
+	Subtotal           uint32              `json:"subtotal" bson:"subtotal"`
+	Shipping_Fee       uint32              `json:"shipping_fee" bson:"shipping_fee"`
+	Discount           uint8               `json:"discount" bson:"discount"`
+	Discount_Amount    uint32              `json:"discount_amount" bson:"discount_amount"`
+	Total              uint32              `json:"total" bson:"total"`
+	Estimated_Delivery *time.Time          `json:"estimated_delivery" bson:"estimated_delivery,omitempty"`
+	Order_Id           *primitive.ObjectID `json:"order_id" bson:"order_id,omitempty"`
+	Created_At         time.Time           `json:"created_at" bson:"created_at"`
+	Updated_At         time.Time           `json:"updated_at" bson:"updated_at"`
+	Expires_At         time.Time           `json:"expires_at" bson:"expires_at"`
+}
+
+type Category struct {
+	ID         primitive.ObjectID   `json:"_id" bson:"_id,omitempty"`
+	Name       *string              `json:"name" bson:"name" validate:"required,min=2,max=100"`
+	Slug       string               `json:"slug" bson:"slug" validate:"omitempty,max=100"`
+	Parent_Id  *primitive.ObjectID  `json:"parent_id" bson:"parent_id,omitempty"`
+	Ancestors  []primitive.ObjectID `json:"ancestors" bson:"ancestors"`
+	Sort_Order int                  `json:"sort_order" bson:"sort_order"`
+	Created_At time.Time            `json:"created_at" bson:"created_at"`
+	Updated_At time.Time            `json:"updated_at" bson:"updated_at"`
+}
+
+type SearchQueryStat struct {
+	ID               primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Query            string             `json:"query" bson:"query"`
+	Count            int64              `json:"count" bson:"count"`
+	Last_Searched_At time.Time          `json:"last_searched_at" bson:"last_searched_at"`
+}
+
+type SynonymSet struct {
+	ID         primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Terms      []string           `json:"terms" bson:"terms" validate:"required,min=2,dive,required"`
+	Created_At time.Time          `json:"created_at" bson:"created_at"`
+}
+
+type QueryRule struct {
+	ID          primitive.ObjectID   `json:"_id" bson:"_id,omitempty"`
+	Query       string               `json:"query" bson:"query" validate:"required"`
+	Action      string               `json:"action" bson:"action" validate:"required,oneof=boost pin hide redirect"`
+	Product_Ids []primitive.ObjectID `json:"product_ids" bson:"product_ids,omitempty"`
+	Category_Id *primitive.ObjectID  `json:"category_id" bson:"category_id,omitempty"`
+	Boost       *float64             `json:"boost" bson:"boost,omitempty" validate:"omitempty,gt=0"`
+	Active      bool                 `json:"active" bson:"active"`
+	Created_At  time.Time            `json:"created_at" bson:"created_at"`
+}
+
+type SearchEvent struct {
+	ID               primitive.ObjectID   `json:"_id" bson:"_id,omitempty"`
+	Query            string               `json:"query" bson:"query"`
+	Result_Count     int64                `json:"result_count" bson:"result_count"`
+	User_Id          string               `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	Clicked_Products []primitive.ObjectID `json:"clicked_products" bson:"clicked_products"`
+	Carted_Products  []primitive.ObjectID `json:"carted_products" bson:"carted_products"`
+	Redirect         string               `json:"redirect,omitempty" bson:"redirect,omitempty"`
+	Searched_At      time.Time            `json:"searched_at" bson:"searched_at"`
+}
+
+type Review struct {
+	ID            primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Product_Id    primitive.ObjectID `json:"product_id" bson:"product_id"`
+	User_Id       string             `json:"user_id" bson:"user_id"`
+	Order_Id      primitive.ObjectID `json:"order_id" bson:"order_id"`
+	Rating        uint8              `json:"rating" bson:"rating" validate:"required,min=1,max=5"`
+	Title         *string            `json:"title" bson:"title,omitempty" validate:"omitempty,max=150"`
+	Body          *string            `json:"body" bson:"body" validate:"required,min=1,max=5000"`
+	Status        string             `json:"status" bson:"status"`
+	Helpful_Votes int                `json:"helpful_votes" bson:"helpful_votes"`
+	Voters        []string           `json:"-" bson:"voters"`
+	Created_At    time.Time          `json:"created_at" bson:"created_at"`
+	Moderated_At  *time.Time         `json:"moderated_at,omitempty" bson:"moderated_at,omitempty"`
+}
+
+type Question struct {
+	ID             primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Product_Id     primitive.ObjectID `json:"product_id" bson:"product_id"`
+	User_Id        string             `json:"user_id" bson:"user_id"`
+	Body           *string            `json:"body" bson:"body" validate:"required,min=1,max=1000"`
+	Verified_Buyer bool               `json:"verified_buyer" bson:"verified_buyer"`
+	Status         string             `json:"status" bson:"status"`
+	Upvotes        int                `json:"upvotes" bson:"upvotes"`
+	Voters         []string           `json:"-" bson:"voters"`
+	Answers        []Answer           `json:"answers" bson:"answers"`
+	Created_At     time.Time          `json:"created_at" bson:"created_at"`
+}
+
+type Answer struct {
+	ID             primitive.ObjectID `json:"_id" bson:"_id"`
+	User_Id        string             `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	Body           *string            `json:"body" bson:"body" validate:"required,min=1,max=2000"`
+	Seller         bool               `json:"seller" bson:"seller"`
+	Verified_Buyer bool               `json:"verified_buyer" bson:"verified_buyer"`
+	Status         string             `json:"status" bson:"status"`
+	Upvotes        int                `json:"upvotes" bson:"upvotes"`
+	Voters         []string           `json:"-" bson:"voters"`
+	Created_At     time.Time          `json:"created_at" bson:"created_at"`
+}
+
+type ProductVersion struct {
+	ID          primitive.ObjectID     `json:"_id" bson:"_id,omitempty"`
+	Product_Id  primitive.ObjectID     `json:"product_id" bson:"product_id"`
+	Version     int                    `json:"version" bson:"version"`
+	Action      string                 `json:"action" bson:"action"`
+	Changed_By  string                 `json:"changed_by" bson:"changed_by"`
+	Changed_At  time.Time              `json:"changed_at" bson:"changed_at"`
+	Changes     map[string]FieldChange `json:"changes" bson:"changes"`
+	Reverted_To *int                   `json:"reverted_to,omitempty" bson:"reverted_to,omitempty"`
+	Snapshot    Product                `json:"snapshot" bson:"snapshot"`
+}
+
+type FieldChange struct {
+	From interface{} `json:"from" bson:"from"`
+	To   interface{} `json:"to" bson:"to"`
+}
+
+type ImportRowError struct {
+	Row   int    `json:"row" bson:"row"`
+	SKU   string `json:"sku,omitempty" bson:"sku,omitempty"`
+	Error string `json:"error" bson:"error"`
+}
+
+type ImportJob struct {
+	ID               primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	Format           string             `json:"format" bson:"format"`
+	Dry_Run          bool               `json:"dry_run" bson:"dry_run"`
+	Status           string             `json:"status" bson:"status"`
+	Processed        int                `json:"processed" bson:"processed"`
+	Created          int                `json:"created" bson:"created"`
+	Updated          int                `json:"updated" bson:"updated"`
+	Failed           int                `json:"failed" bson:"failed"`
+	Errors           []ImportRowError   `json:"-" bson:"errors"`
+	Errors_Truncated bool               `json:"errors_truncated" bson:"errors_truncated"`
+	Error            string             `json:"error,omitempty" bson:"error,omitempty"`
+	Created_By       string             `json:"created_by" bson:"created_by"`
+	Created_At       time.Time          `json:"created_at" bson:"created_at"`
+	Finished_At      *time.Time         `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+}
+
+type ProductImage struct {
+	ID           primitive.ObjectID `json:"_id" bson:"_id"`
+	Key          string             `json:"-" bson:"key"`
+	URL          string             `json:"url" bson:"url"`
+	Content_Type string             `json:"content_type" bson:"content_type"`
+	Width        int                `json:"width" bson:"width"`
+	Height       int                `json:"height" bson:"height"`
+	Size         int64              `json:"size" bson:"size"`
+	Alt          *string            `json:"alt,omitempty" bson:"alt,omitempty"`
+	Thumbnails   []Thumbnail        `json:"thumbnails" bson:"thumbnails"`
+	Uploaded_At  time.Time          `json:"uploaded_at" bson:"uploaded_at"`
+}
+
+type Thumbnail struct {
+	Name   string `json:"name" bson:"name"`
+	Key    string `json:"-" bson:"key"`
+	URL    string `json:"url" bson:"url"`
+	Width  int    `json:"width" bson:"width"`
+	Height int    `json:"height" bson:"height"`
+}
+
+// ProductAssociation holds the products recommended alongside a product,
+// rebuilt periodically from order history and the catalogue.
+type ProductAssociation struct {
+	ID                         primitive.ObjectID `json:"product_id" bson:"_id"`
+	Frequently_Bought_Together []RelatedProduct   `json:"frequently_bought_together" bson:"frequently_bought_together"`
+	Similar                    []RelatedProduct   `json:"similar" bson:"similar"`
+	Computed_At                time.Time          `json:"computed_at" bson:"computed_at"`
+}
+
+type RelatedProduct struct {
+	Product_Id primitive.ObjectID `json:"product_id" bson:"product_id"`
+	Score      float64            `json:"score" bson:"score"`
+}
+
+type RecentView struct {
+	Product_Id primitive.ObjectID `json:"product_id" bson:"product_id"`
+	Viewed_At  time.Time          `json:"viewed_at" bson:"viewed_at"`
+}
+
+type Wishlist struct {
+	ID          primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	User_Id     string             `json:"user_id" bson:"user_id"`
+	Name        *string            `json:"name" bson:"name" validate:"required,min=1,max=100"`
+	Public      bool               `json:"public" bson:"public"`
+	Share_Token string             `json:"share_token,omitempty" bson:"share_token,omitempty"`
+	Items       []WishlistItem     `json:"items" bson:"items"`
+	Created_At  time.Time          `json:"created_at" bson:"created_at"`
+	Updated_At  time.Time          `json:"updated_at" bson:"updated_at"`
+}
+
+type WishlistItem struct {
+	Product_Id primitive.ObjectID `json:"product_id" bson:"product_id"`
+	SKU        *string            `json:"sku,omitempty" bson:"sku,omitempty"`
+	Added_At   time.Time          `json:"added_at" bson:"added_at"`
+}
+
+// Alert asks for a notification when a product, or one SKU of it, is back
+// in stock or its price falls to Target_Price. It fires once.
+type Alert struct {
+	ID           primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	User_Id      string             `json:"user_id" bson:"user_id"`
+	Product_Id   primitive.ObjectID `json:"product_id" bson:"product_id" validate:"required"`
+	SKU          *string            `json:"sku,omitempty" bson:"sku,omitempty"`
+	Kind         string             `json:"kind" bson:"kind" validate:"required,oneof=back_in_stock price_drop"`
+	Target_Price *uint32            `json:"target_price,omitempty" bson:"target_price,omitempty" validate:"required_if=Kind price_drop"`
+	Active       bool               `json:"active" bson:"active"`
+	Created_At   time.Time          `json:"created_at" bson:"created_at"`
+	Triggered_At *time.Time         `json:"triggered_at,omitempty" bson:"triggered_at,omitempty"`
+}
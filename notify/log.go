@@ -0,0 +1,18 @@
+package notify
+
+import (
+	"context"
+	"log"
+)
+
+// Log writes each message to the standard logger.
+type Log struct{}
+
+func NewLog() *Log {
+	return &Log{}
+}
+
+func (Log) Notify(ctx context.Context, message Message) error {
+	log.Printf("notify %s [%s]: %s - %s %s", message.User_Id, message.Kind, message.Subject, message.Body, message.Link)
+	return nil
+}
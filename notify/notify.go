@@ -0,0 +1,23 @@
+// Package notify delivers messages to customers. Notifier is the extension
+// point for real channels such as email or push; Log and File are sinks for
+// development.
+package notify
+
+import (
+	"context"
+	"time"
+)
+
+// Message is one notification for a user.
+type Message struct {
+	User_Id    string    `json:"user_id"`
+	Kind       string    `json:"kind"`
+	Subject    string    `json:"subject"`
+	Body       string    `json:"body"`
+	Link       string    `json:"link,omitempty"`
+	Created_At time.Time `json:"created_at"`
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, message Message) error
+}
@@ -2,13 +2,25 @@ package routes
 
 import (
 	"github.com/djwhocodes/ecom_cart_golang/controllers"
+	"github.com/djwhocodes/ecom_cart_golang/middleware"
 	"github.com/gin-gonic/gin"
 )
 
 func UserRoutes(router *gin.Engine) {
 	router.POST("/users/signup", controllers.SignUp())
 	router.POST("/users/login", controllers.Login())
-	router.POST("/users/addproduct", controllers.ProductViewerAdmin())
+	router.POST("/users/addproduct", middleware.AdminOnly(), controllers.ProductViewerAdmin())
 	router.GET("/users/productview", controllers.SearchProduct())
 	router.GET("/users/search", controllers.SearchProductByQuery())
+	router.GET("/users/search/suggest", controllers.SuggestSearch())
+	router.POST("/users/search/click", controllers.RecordSearchClick())
+	router.GET("/users/serviceability", controllers.CheckServiceability())
+	router.GET("/users/categories", controllers.CategoryTree())
+	router.GET("/users/categories/:slug/products", controllers.BrowseCategory())
+	router.GET("/users/products/:id", controllers.ProductDetail())
+	router.GET("/users/products/:id/reviews", controllers.ProductReviews())
+	router.GET("/users/products/:id/questions", controllers.ProductQuestions())
+	router.GET("/users/products/:id/related", controllers.RelatedProducts())
+	router.GET("/users/images/*key", controllers.ServeImage())
+	router.GET("/users/wishlists/shared/:token", controllers.SharedWishlist())
 }
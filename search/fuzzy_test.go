@@ -0,0 +1,82 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		limit int
+		want  int
+	}{
+		{"shirt", "shirt", 2, 0},
+		{"shirt", "shrt", 2, 1},
+		{"shirt", "shirts", 2, 1},
+		{"shirt", "skirt", 2, 1},
+		{"shirt", "shrit", 2, 1},
+		{"kitten", "sitting", 3, 3},
+		{"", "abc", 3, 3},
+		{"café", "cafe", 1, 1},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b, tt.limit); got != tt.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestEditDistanceOverLimit(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		limit int
+	}{
+		{"kitten", "sitting", 1},
+		{"abc", "", 1},
+		{"wallet", "jacket", 2},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b, tt.limit); got <= tt.limit {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want more than %d", tt.a, tt.b, tt.limit, got, tt.limit)
+		}
+	}
+}
+
+func TestMaxEdits(t *testing.T) {
+	tests := []struct {
+		term string
+		want int
+	}{
+		{"tee", 0},
+		{"shirt", 1},
+		{"jackets", 1},
+		{"sweaters", 2},
+	}
+
+	for _, tt := range tests {
+		if got := maxEdits(tt.term); got != tt.want {
+			t.Errorf("maxEdits(%q) = %d, want %d", tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestTrigrams(t *testing.T) {
+	tests := []struct {
+		term string
+		want []string
+	}{
+		{"a", []string{"  a", " a "}},
+		{"cat", []string{"  c", " ca", "cat", "at "}},
+		{"aaaa", []string{"  a", " aa", "aaa", "aa "}},
+		{"né", []string{"  n", " né", "né "}},
+	}
+
+	for _, tt := range tests {
+		if got := trigrams(tt.term); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("trigrams(%q) = %q, want %q", tt.term, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package search
+
+import (
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Field weights, matching the weights on the database text index.
+const (
+	nameWeight        = 10
+	attributeWeight   = 3
+	descriptionWeight = 1
+)
+
+type Hit struct {
+	ID    primitive.ObjectID
+	Score float64
+}
+
+type document struct {
+	terms map[string]float64
+	text  string
+}
+
+// Index is an in-memory inverted index over the product catalogue. It is
+// safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	ready    bool
+	docs     map[primitive.ObjectID]*document
+	postings map[string]map[primitive.ObjectID]float64
+	grams    map[string]map[string]bool
+
+	// While a rebuild runs, writes are also kept in pending so they can be
+	// applied again to the rebuilt index; a nil product is a removal.
+	rebuilding int
+	pending    map[primitive.ObjectID]*models.Product
+}
+
+func NewIndex() *Index {
+	return &Index{
+		docs:     map[primitive.ObjectID]*document{},
+		postings: map[string]map[primitive.ObjectID]float64{},
+		grams:    map[string]map[string]bool{},
+	}
+}
+
+// Ready reports whether the index has been fully built at least once.
+func (idx *Index) Ready() bool {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.ready
+}
+
+// Rebuild replaces the whole index with the products returned by load.
+// Puts and removes made while load runs or the index is built are applied
+// again after the swap, so they aren't lost to a stale catalogue.
+func (idx *Index) Rebuild(load func() ([]models.Product, error)) error {
+	idx.mu.Lock()
+	if idx.rebuilding == 0 {
+		idx.pending = map[primitive.ObjectID]*models.Product{}
+	}
+	idx.rebuilding++
+	idx.mu.Unlock()
+
+	products, err := load()
+
+	fresh := NewIndex()
+	if err == nil {
+		for _, product := range products {
+			fresh.put(product)
+		}
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.rebuilding--
+	if err != nil {
+		return err
+	}
+
+	idx.docs = fresh.docs
+	idx.postings = fresh.postings
+	idx.grams = fresh.grams
+	idx.ready = true
+	for id, product := range idx.pending {
+		idx.remove(id)
+		if product != nil {
+			idx.put(*product)
+		}
+	}
+	if idx.rebuilding == 0 {
+		idx.pending = nil
+	}
+	return nil
+}
+
+// Put adds product to the index, replacing any previous version of it.
+func (idx *Index) Put(product models.Product) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(product.ID)
+	idx.put(product)
+	if idx.rebuilding > 0 {
+		idx.pending[product.ID] = &product
+	}
+}
+
+func (idx *Index) Remove(id primitive.ObjectID) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.remove(id)
+	if idx.rebuilding > 0 {
+		idx.pending[id] = nil
+	}
+}
+
+func (idx *Index) put(product models.Product) {
+	doc := &document{terms: map[string]float64{}}
+	var text []string
+
+	add := func(s string, weight float64) {
+		for _, term := range Terms(s) {
+			doc.terms[term] += weight
+		}
+		text = append(text, Tokenize(s)...)
+	}
+
+	if product.Product_Name != nil {
+		add(*product.Product_Name, nameWeight)
+	}
+	for _, value := range product.Attributes {
+		add(value, attributeWeight)
+	}
+	if product.Description != nil {
+		add(*product.Description, descriptionWeight)
+	}
+
+	doc.text = " " + strings.Join(text, " ") + " "
+	idx.docs[product.ID] = doc
+
+	for term, weight := range doc.terms {
+		if idx.postings[term] == nil {
+			idx.postings[term] = map[primitive.ObjectID]float64{}
+			for _, gram := range trigrams(term) {
+				if idx.grams[gram] == nil {
+					idx.grams[gram] = map[string]bool{}
+				}
+				idx.grams[gram][term] = true
+			}
+		}
+		idx.postings[term][product.ID] = weight
+	}
+}
+
+func (idx *Index) remove(id primitive.ObjectID) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+
+	for term := range doc.terms {
+		delete(idx.postings[term], id)
+		if len(idx.postings[term]) > 0 {
+			continue
+		}
+		delete(idx.postings, term)
+		for _, gram := range trigrams(term) {
+			delete(idx.grams[gram], term)
+			if len(idx.grams[gram]) == 0 {
+				delete(idx.grams, gram)
+			}
+		}
+	}
+
+	delete(idx.docs, id)
+}
+
+// expand returns the indexed terms that term may refer to, with a penalty
+// factor: 1 for an exact match, less for fuzzy matches the further they are.
+func (idx *Index) expand(term string) map[string]float64 {
+	if _, ok := idx.postings[term]; ok {
+		return map[string]float64{term: 1}
+	}
+
+	limit := maxEdits(term)
+	if limit == 0 {
+		return nil
+	}
+
+	grams := trigrams(term)
+	shared := map[string]int{}
+	for _, gram := range grams {
+		for candidate := range idx.grams[gram] {
+			shared[candidate]++
+		}
+	}
+
+	matches := map[string]float64{}
+	for candidate, count := range shared {
+		// Each edit destroys at most three trigrams, a transposition four.
+		if count < len(grams)-4*limit {
+			continue
+		}
+		if d := editDistance(term, candidate, limit); d <= limit {
+			matches[candidate] = 1 / float64(1+d)
+		}
+	}
+
+	return matches
+}
+
+// Search ranks documents against the query terms, tolerating typos. Every
+// phrase must appear verbatim and no excluded term may appear; with matchAll
+// every term must match too.
+func (idx *Index) Search(terms, phrases, exclude []string, matchAll bool) []Hit {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	seen := map[string]bool{}
+	var queryTerms []string
+	for _, term := range Terms(strings.Join(append(append([]string{}, terms...), phrases...), " ")) {
+		if !seen[term] {
+			seen[term] = true
+			queryTerms = append(queryTerms, term)
+		}
+	}
+	if len(queryTerms) == 0 {
+		return nil
+	}
+
+	n := float64(len(idx.docs))
+	scores := map[primitive.ObjectID]float64{}
+	matched := map[primitive.ObjectID]int{}
+
+	for _, queryTerm := range queryTerms {
+		best := map[primitive.ObjectID]float64{}
+		for term, penalty := range idx.expand(queryTerm) {
+			postings := idx.postings[term]
+			idf := math.Log(1 + n/float64(len(postings)))
+			for id, weight := range postings {
+				best[id] = max(best[id], weight*idf*penalty)
+			}
+		}
+		for id, score := range best {
+			scores[id] += score
+			matched[id]++
+		}
+	}
+
+	excluded := Terms(strings.Join(exclude, " "))
+	normalized := make([]string, 0, len(phrases))
+	for _, phrase := range phrases {
+		if tokens := Tokenize(phrase); len(tokens) > 0 {
+			normalized = append(normalized, " "+strings.Join(tokens, " ")+" ")
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		doc := idx.docs[id]
+		if !keep(doc, normalized, excluded) || (matchAll && matched[id] < len(queryTerms)) {
+			continue
+		}
+		coverage := float64(matched[id]) / float64(len(queryTerms))
+		hits = append(hits, Hit{ID: id, Score: score * coverage * coverage})
+	}
+
+	SortHits(hits)
+	return hits
+}
+
+func keep(doc *document, phrases, excluded []string) bool {
+	for _, phrase := range phrases {
+		if !strings.Contains(doc.text, phrase) {
+			return false
+		}
+	}
+	for _, term := range excluded {
+		if _, ok := doc.terms[term]; ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func testProduct(name, description string) models.Product {
+	product := models.Product{ID: primitive.NewObjectID(), Product_Name: &name}
+	if description != "" {
+		product.Description = &description
+	}
+	return product
+}
+
+func hitIDs(hits []Hit) []primitive.ObjectID {
+	ids := []primitive.ObjectID{}
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+func TestIndexSearch(t *testing.T) {
+	shirt := testProduct("Cotton T-Shirt", "Soft cotton tee")
+	wallet := testProduct("Leather Wallet", "")
+	shoes := testProduct("Running Shoes", "Lightweight shoes for running")
+	socks := testProduct("Cotton Socks", "")
+	tote := testProduct("Canvas Tote", "Fits a cotton shirt")
+
+	idx := NewIndex()
+	for _, product := range []models.Product{shirt, wallet, shoes, socks, tote} {
+		idx.Put(product)
+	}
+
+	tests := []struct {
+		name     string
+		terms    []string
+		phrases  []string
+		exclude  []string
+		matchAll bool
+		want     []primitive.ObjectID
+	}{
+		{name: "exact", terms: []string{"wallet"}, want: []primitive.ObjectID{wallet.ID}},
+		{name: "stemmed", terms: []string{"run"}, want: []primitive.ObjectID{shoes.ID}},
+		{name: "transposed letters", terms: []string{"shrit"}, want: []primitive.ObjectID{shirt.ID, tote.ID}},
+		{name: "missing letter", terms: []string{"walet"}, want: []primitive.ObjectID{wallet.ID}},
+		{
+			name:  "name before description before partial",
+			terms: []string{"coton", "shirt"},
+			want:  []primitive.ObjectID{shirt.ID, tote.ID, socks.ID},
+		},
+		{
+			name:     "match all",
+			terms:    []string{"coton", "socks"},
+			matchAll: true,
+			want:     []primitive.ObjectID{socks.ID},
+		},
+		{
+			name:    "excluded term",
+			terms:   []string{"cotton"},
+			exclude: []string{"shirts"},
+			want:    []primitive.ObjectID{socks.ID},
+		},
+		{name: "phrase", phrases: []string{"t-shirt"}, want: []primitive.ObjectID{shirt.ID}},
+		{name: "too far off", terms: []string{"jacket"}, want: []primitive.ObjectID{}},
+		{name: "short terms need exact matches", terms: []string{"tea"}, want: []primitive.ObjectID{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hitIDs(idx.Search(tt.terms, tt.phrases, tt.exclude, tt.matchAll))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexRemove(t *testing.T) {
+	wallet := testProduct("Leather Wallet", "")
+	idx := NewIndex()
+	idx.Put(wallet)
+	idx.Remove(wallet.ID)
+
+	if hits := idx.Search([]string{"wallet"}, nil, nil, false); len(hits) != 0 {
+		t.Errorf("Search() after Remove = %v, want no hits", hitIDs(hits))
+	}
+	if len(idx.postings) != 0 || len(idx.grams) != 0 {
+		t.Errorf("Remove left %d postings and %d grams behind", len(idx.postings), len(idx.grams))
+	}
+}
+
+func TestIndexRebuildKeepsConcurrentWrites(t *testing.T) {
+	wallet := testProduct("Leather Wallet", "")
+	socks := testProduct("Cotton Socks", "")
+	added := testProduct("Canvas Tote", "")
+
+	idx := NewIndex()
+	err := idx.Rebuild(func() ([]models.Product, error) {
+		// These writes land while the catalogue is being read, so the
+		// catalogue doesn't reflect them.
+		idx.Put(added)
+		idx.Remove(socks.ID)
+		return []models.Product{wallet, socks}, nil
+	})
+	if err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+
+	if !idx.Ready() {
+		t.Error("Ready() = false after Rebuild")
+	}
+	for _, tt := range []struct {
+		term string
+		want []primitive.ObjectID
+	}{
+		{"wallet", []primitive.ObjectID{wallet.ID}},
+		{"tote", []primitive.ObjectID{added.ID}},
+		{"socks", []primitive.ObjectID{}},
+	} {
+		if got := hitIDs(idx.Search([]string{tt.term}, nil, nil, false)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.term, got, tt.want)
+		}
+	}
+	if idx.pending != nil {
+		t.Errorf("pending = %v after Rebuild, want nil", idx.pending)
+	}
+}
+
+func TestIndexRebuildError(t *testing.T) {
+	wallet := testProduct("Leather Wallet", "")
+	idx := NewIndex()
+	idx.Put(wallet)
+
+	loadErr := errors.New("load failed")
+	if err := idx.Rebuild(func() ([]models.Product, error) { return nil, loadErr }); err != loadErr {
+		t.Fatalf("Rebuild() error = %v, want %v", err, loadErr)
+	}
+	if idx.Ready() {
+		t.Error("Ready() = true after a failed Rebuild")
+	}
+	if got := hitIDs(idx.Search([]string{"wallet"}, nil, nil, false)); !reflect.DeepEqual(got, []primitive.ObjectID{wallet.ID}) {
+		t.Errorf("Search() after a failed Rebuild = %v, want the existing index kept", got)
+	}
+}
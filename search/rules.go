@@ -0,0 +1,201 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxQueryVariants bounds how many synonym rewrites a single query expands to.
+const maxQueryVariants = 10
+
+const defaultBoost = 2
+
+// RuleSet holds the merchandising synonyms and query rules applied to
+// searches. It is safe for concurrent use.
+type RuleSet struct {
+	mu       sync.RWMutex
+	synonyms map[string][]string
+	rules    map[string][]models.QueryRule
+}
+
+func NewRuleSet() *RuleSet {
+	return &RuleSet{
+		synonyms: map[string][]string{},
+		rules:    map[string][]models.QueryRule{},
+	}
+}
+
+// NormalizePhrase lowercases s and joins its tokens with single spaces, the
+// form synonyms and rule queries are matched in.
+func NormalizePhrase(s string) string {
+	return strings.Join(Tokenize(s), " ")
+}
+
+func (r *RuleSet) Load(sets []models.SynonymSet, rules []models.QueryRule) {
+	synonyms := map[string][]string{}
+	for _, set := range sets {
+		var terms []string
+		for _, term := range set.Terms {
+			if term = NormalizePhrase(term); term != "" {
+				terms = append(terms, term)
+			}
+		}
+		for _, term := range terms {
+			for _, other := range terms {
+				if other != term {
+					synonyms[term] = append(synonyms[term], other)
+				}
+			}
+		}
+	}
+
+	byQuery := map[string][]models.QueryRule{}
+	for _, rule := range rules {
+		if rule.Active {
+			query := NormalizePhrase(rule.Query)
+			byQuery[query] = append(byQuery[query], rule)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.synonyms = synonyms
+	r.rules = byQuery
+}
+
+// Expand returns query followed by its synonym rewrites, each replacing one
+// synonym phrase found in the query with one of its alternatives.
+func (r *RuleSet) Expand(query string) []string {
+	variants := []string{query}
+
+	normalized := " " + NormalizePhrase(query) + " "
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	phrases := make([]string, 0, len(r.synonyms))
+	for phrase := range r.synonyms {
+		phrases = append(phrases, phrase)
+	}
+	sort.Strings(phrases)
+
+	for _, phrase := range phrases {
+		if !strings.Contains(normalized, " "+phrase+" ") {
+			continue
+		}
+		for _, alternative := range r.synonyms[phrase] {
+			if len(variants) >= maxQueryVariants {
+				return variants
+			}
+			variant := strings.Replace(normalized, " "+phrase+" ", " "+alternative+" ", 1)
+			variants = append(variants, strings.TrimSpace(variant))
+		}
+	}
+
+	return variants
+}
+
+func (r *RuleSet) Rules(query string) []models.QueryRule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rules[NormalizePhrase(query)]
+}
+
+// Redirect returns the first redirect rule for query, if any.
+func (r *RuleSet) Redirect(query string) (models.QueryRule, bool) {
+	for _, rule := range r.Rules(query) {
+		if rule.Action == "redirect" && rule.Category_Id != nil {
+			return rule, true
+		}
+	}
+	return models.QueryRule{}, false
+}
+
+// ApplyRules hides, boosts and pins products in a ranked hit list. Pinned
+// products go first in the order given, even if they didn't match.
+func ApplyRules(hits []Hit, rules []models.QueryRule) []Hit {
+	hidden := map[primitive.ObjectID]bool{}
+	boosts := map[primitive.ObjectID]float64{}
+	var pinned []primitive.ObjectID
+
+	for _, rule := range rules {
+		switch rule.Action {
+		case "hide":
+			for _, id := range rule.Product_Ids {
+				hidden[id] = true
+			}
+		case "boost":
+			factor := float64(defaultBoost)
+			if rule.Boost != nil {
+				factor = *rule.Boost
+			}
+			for _, id := range rule.Product_Ids {
+				boosts[id] = factor
+			}
+		case "pin":
+			pinned = append(pinned, rule.Product_Ids...)
+		}
+	}
+
+	isPinned := map[primitive.ObjectID]bool{}
+	var result []Hit
+	for _, id := range pinned {
+		if !hidden[id] && !isPinned[id] {
+			isPinned[id] = true
+			result = append(result, Hit{ID: id})
+		}
+	}
+
+	var rest []Hit
+	for _, hit := range hits {
+		if hidden[hit.ID] || isPinned[hit.ID] {
+			continue
+		}
+		if factor, ok := boosts[hit.ID]; ok {
+			hit.Score *= factor
+		}
+		rest = append(rest, hit)
+	}
+	SortHits(rest)
+
+	return append(result, rest...)
+}
+
+// SortHits orders hits by descending score, breaking ties by ID.
+func SortHits(hits []Hit) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID.Hex() < hits[j].ID.Hex()
+	})
+}
+
+// MergeHits combines ranked lists, keeping each document's best score.
+// Lists after the first are scaled by weight, so the literal query outranks
+// its rewrites.
+func MergeHits(lists [][]Hit, weight float64) []Hit {
+	best := map[primitive.ObjectID]float64{}
+	for i, list := range lists {
+		factor := 1.0
+		if i > 0 {
+			factor = weight
+		}
+		for _, hit := range list {
+			if score := hit.Score * factor; score > best[hit.ID] {
+				best[hit.ID] = score
+			}
+		}
+	}
+
+	merged := make([]Hit, 0, len(best))
+	for id, score := range best {
+		merged = append(merged, Hit{ID: id, Score: score})
+	}
+	SortHits(merged)
+	return merged
+}
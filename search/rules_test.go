@@ -0,0 +1,144 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/djwhocodes/ecom_cart_golang/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func testID(n byte) primitive.ObjectID {
+	return primitive.ObjectID{11: n}
+}
+
+func TestApplyRules(t *testing.T) {
+	hits := []Hit{
+		{ID: testID(1), Score: 10},
+		{ID: testID(2), Score: 8},
+		{ID: testID(3), Score: 6},
+		{ID: testID(4), Score: 4},
+	}
+	triple := 3.0
+
+	tests := []struct {
+		name  string
+		rules []models.QueryRule
+		want  []Hit
+	}{
+		{
+			name: "no rules",
+			want: hits,
+		},
+		{
+			name:  "hide",
+			rules: []models.QueryRule{{Action: "hide", Product_Ids: []primitive.ObjectID{testID(1), testID(3)}}},
+			want:  []Hit{{ID: testID(2), Score: 8}, {ID: testID(4), Score: 4}},
+		},
+		{
+			name:  "default boost",
+			rules: []models.QueryRule{{Action: "boost", Product_Ids: []primitive.ObjectID{testID(3)}}},
+			want:  []Hit{{ID: testID(3), Score: 12}, {ID: testID(1), Score: 10}, {ID: testID(2), Score: 8}, {ID: testID(4), Score: 4}},
+		},
+		{
+			name:  "boost factor",
+			rules: []models.QueryRule{{Action: "boost", Product_Ids: []primitive.ObjectID{testID(4)}, Boost: &triple}},
+			want:  []Hit{{ID: testID(4), Score: 12}, {ID: testID(1), Score: 10}, {ID: testID(2), Score: 8}, {ID: testID(3), Score: 6}},
+		},
+		{
+			name: "pins go first in order, matched or not",
+			rules: []models.QueryRule{
+				{Action: "pin", Product_Ids: []primitive.ObjectID{testID(3), testID(9)}},
+				{Action: "pin", Product_Ids: []primitive.ObjectID{testID(3), testID(2)}},
+			},
+			want: []Hit{{ID: testID(3)}, {ID: testID(9)}, {ID: testID(2)}, {ID: testID(1), Score: 10}, {ID: testID(4), Score: 4}},
+		},
+		{
+			name: "hide beats pin and boost",
+			rules: []models.QueryRule{
+				{Action: "pin", Product_Ids: []primitive.ObjectID{testID(4)}},
+				{Action: "boost", Product_Ids: []primitive.ObjectID{testID(3)}},
+				{Action: "hide", Product_Ids: []primitive.ObjectID{testID(4), testID(3)}},
+			},
+			want: []Hit{{ID: testID(1), Score: 10}, {ID: testID(2), Score: 8}},
+		},
+		{
+			name:  "pin outranks boost",
+			rules: []models.QueryRule{{Action: "boost", Product_Ids: []primitive.ObjectID{testID(4)}, Boost: &triple}, {Action: "pin", Product_Ids: []primitive.ObjectID{testID(2)}}},
+			want:  []Hit{{ID: testID(2)}, {ID: testID(4), Score: 12}, {ID: testID(1), Score: 10}, {ID: testID(3), Score: 6}},
+		},
+		{
+			name:  "redirects don't reorder",
+			rules: []models.QueryRule{{Action: "redirect", Product_Ids: []primitive.ObjectID{testID(4)}}},
+			want:  hits,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := append([]Hit{}, hits...)
+			if got := ApplyRules(input, tt.rules); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ApplyRules() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleSetExpand(t *testing.T) {
+	many := []string{"a1", "a2", "a3", "a4", "a5", "a6", "a7", "a8", "a9", "a10", "a11", "a12"}
+
+	r := NewRuleSet()
+	r.Load([]models.SynonymSet{
+		{Terms: []string{"tee", "T-Shirt"}},
+		{Terms: []string{"Sofa", "couch", "settee"}},
+		{Terms: many},
+	}, nil)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"red tee", []string{"red tee", "red t shirt"}},
+		{"blue T-Shirt", []string{"blue T-Shirt", "blue tee"}},
+		{"Comfy SOFA", []string{"Comfy SOFA", "comfy couch", "comfy settee"}},
+		{"tee sofa", []string{"tee sofa", "tee couch", "tee settee", "t shirt sofa"}},
+		{"teepee", []string{"teepee"}},
+		{"", []string{""}},
+	}
+
+	for _, tt := range tests {
+		if got := r.Expand(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Expand(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+
+	if got := r.Expand("a1"); len(got) != maxQueryVariants {
+		t.Errorf("Expand(%q) returned %d variants, want %d", "a1", len(got), maxQueryVariants)
+	}
+}
+
+func TestRuleSetRules(t *testing.T) {
+	category := testID(7)
+	r := NewRuleSet()
+	r.Load(nil, []models.QueryRule{
+		{Query: "Red Shoes", Action: "boost", Product_Ids: []primitive.ObjectID{testID(1)}, Active: true},
+		{Query: "red  shoes", Action: "hide", Product_Ids: []primitive.ObjectID{testID(2)}},
+		{Query: "sale", Action: "redirect", Active: true},
+		{Query: "sale", Action: "redirect", Category_Id: &category, Active: true},
+	})
+
+	if got := r.Rules("  RED shoes "); len(got) != 1 || got[0].Action != "boost" {
+		t.Errorf("Rules() = %v, want only the active boost rule", got)
+	}
+	if got := r.Rules("shoes"); len(got) != 0 {
+		t.Errorf("Rules(%q) = %v, want none", "shoes", got)
+	}
+
+	rule, ok := r.Redirect("Sale")
+	if !ok || rule.Category_Id == nil || *rule.Category_Id != category {
+		t.Errorf("Redirect() = %v, %v, want the rule with a category", rule, ok)
+	}
+	if _, ok := r.Redirect("red shoes"); ok {
+		t.Error("Redirect() found a redirect for a query without one")
+	}
+}
@@ -0,0 +1,139 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	SuggestProduct  = "product"
+	SuggestCategory = "category"
+	SuggestQuery    = "query"
+)
+
+// MaxSuggestions is the most suggestions a lookup returns.
+const MaxSuggestions = 10
+
+// shortPrefix is the longest prefix, in characters, whose best suggestions
+// are worked out when the suggester is built. These prefixes match too much
+// of the index to rank on every lookup.
+const shortPrefix = 2
+
+type Suggestion struct {
+	Text   string  `json:"text"`
+	Type   string  `json:"type"`
+	ID     string  `json:"id,omitempty"`
+	Slug   string  `json:"slug,omitempty"`
+	Weight float64 `json:"-"`
+}
+
+type suggestKey struct {
+	key   string
+	entry int
+}
+
+// Suggester answers prefix lookups over a fixed set of suggestions. Every
+// word start in a suggestion is a key, so "shi" finds "Cotton T-Shirt".
+type Suggester struct {
+	mu      sync.RWMutex
+	entries []Suggestion
+	keys    []suggestKey
+	top     map[string][]int
+}
+
+func NewSuggester() *Suggester {
+	return &Suggester{}
+}
+
+func (s *Suggester) Rebuild(entries []Suggestion) {
+	var keys []suggestKey
+	for i, entry := range entries {
+		tokens := Tokenize(entry.Text)
+		for j := range tokens {
+			keys = append(keys, suggestKey{key: strings.Join(tokens[j:], " "), entry: i})
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].key < keys[j].key })
+
+	top := map[string][]int{}
+	for _, key := range keys {
+		runes := []rune(key.key)
+		for n := 1; n <= shortPrefix && n <= len(runes); n++ {
+			prefix := string(runes[:n])
+			top[prefix] = rankEntry(entries, top[prefix], key.entry, MaxSuggestions)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = entries
+	s.keys = keys
+	s.top = top
+}
+
+// Suggest returns up to limit suggestions starting with prefix, most popular
+// first.
+func (s *Suggester) Suggest(prefix string, limit int) []Suggestion {
+	prefix = strings.Join(Tokenize(prefix), " ")
+	limit = min(limit, MaxSuggestions)
+	if prefix == "" || limit <= 0 {
+		return []Suggestion{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	best, ok := s.top[prefix]
+	if !ok && len([]rune(prefix)) > shortPrefix {
+		start := sort.Search(len(s.keys), func(i int) bool { return s.keys[i].key >= prefix })
+		for i := start; i < len(s.keys) && strings.HasPrefix(s.keys[i].key, prefix); i++ {
+			best = rankEntry(s.entries, best, s.keys[i].entry, limit)
+		}
+	}
+
+	matches := make([]Suggestion, 0, min(limit, len(best)))
+	for _, entry := range best {
+		if len(matches) == limit {
+			break
+		}
+		matches = append(matches, s.entries[entry])
+	}
+	return matches
+}
+
+// rankEntry adds entry to best, the indexes of at most k entries ordered by
+// weight and then by shorter text, unless it is already there or doesn't
+// make the cut.
+func rankEntry(entries []Suggestion, best []int, entry, k int) []int {
+	at := len(best)
+	for i, other := range best {
+		if other == entry {
+			return best
+		}
+		if at == len(best) && ranksBefore(entries[entry], entries[other]) {
+			at = i
+		}
+	}
+	if at >= k {
+		return best
+	}
+
+	best = append(best, 0)
+	copy(best[at+1:], best[at:])
+	best[at] = entry
+	if len(best) > k {
+		best = best[:k]
+	}
+	return best
+}
+
+func ranksBefore(a, b Suggestion) bool {
+	if a.Weight != b.Weight {
+		return a.Weight > b.Weight
+	}
+	if len(a.Text) != len(b.Text) {
+		return len(a.Text) < len(b.Text)
+	}
+	return a.Text < b.Text
+}
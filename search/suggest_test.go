@@ -0,0 +1,80 @@
+package search
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func suggestionTexts(suggestions []Suggestion) []string {
+	texts := []string{}
+	for _, suggestion := range suggestions {
+		texts = append(texts, suggestion.Text)
+	}
+	return texts
+}
+
+func TestSuggest(t *testing.T) {
+	entries := []Suggestion{
+		{Text: "Cotton T-Shirt", Type: SuggestProduct, Weight: 40},
+		{Text: "Silk Shirt", Type: SuggestProduct, Weight: 25},
+		{Text: "Shirts", Type: SuggestCategory, Weight: 25},
+		{Text: "shoes", Type: SuggestQuery, Weight: 30},
+		{Text: "Shoe Rack", Type: SuggestProduct, Weight: 5},
+		{Text: "Leather Wallet", Type: SuggestProduct, Weight: 1},
+	}
+	// Plenty of unpopular entries that sort before the popular one.
+	for i := 0; i < 600; i++ {
+		entries = append(entries, Suggestion{Text: fmt.Sprintf("aa%04d", i), Type: SuggestProduct, Weight: 1})
+	}
+	entries = append(entries, Suggestion{Text: "Azure Lamp", Type: SuggestProduct, Weight: 50})
+
+	s := NewSuggester()
+	s.Rebuild(entries)
+
+	tests := []struct {
+		name   string
+		prefix string
+		limit  int
+		want   []string
+	}{
+		{name: "by weight", prefix: "sh", limit: 3, want: []string{"Cotton T-Shirt", "shoes", "Shirts"}},
+		{name: "shorter text breaks ties", prefix: "shir", limit: 3, want: []string{"Cotton T-Shirt", "Shirts", "Silk Shirt"}},
+		{name: "matches every word start once", prefix: "s", limit: 10, want: []string{"Cotton T-Shirt", "shoes", "Shirts", "Silk Shirt", "Shoe Rack"}},
+		{name: "popular entry past many matches", prefix: "a", limit: 2, want: []string{"Azure Lamp", "aa0000"}},
+		{name: "several words", prefix: "shoe r", limit: 5, want: []string{"Shoe Rack"}},
+		{name: "normalized prefix", prefix: "  LEATHER ", limit: 5, want: []string{"Leather Wallet"}},
+		{name: "no match", prefix: "xyz", limit: 5, want: []string{}},
+		{name: "no short match", prefix: "x", limit: 5, want: []string{}},
+		{name: "empty prefix", prefix: " ", limit: 5, want: []string{}},
+		{name: "zero limit", prefix: "sh", limit: 0, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := suggestionTexts(s.Suggest(tt.prefix, tt.limit)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Suggest(%q, %d) = %q, want %q", tt.prefix, tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestCapsLimit(t *testing.T) {
+	var entries []Suggestion
+	for i := 0; i < 3*MaxSuggestions; i++ {
+		entries = append(entries, Suggestion{Text: fmt.Sprintf("lamp %02d", i), Weight: float64(i)})
+	}
+
+	s := NewSuggester()
+	s.Rebuild(entries)
+
+	for _, prefix := range []string{"l", "lamp"} {
+		got := s.Suggest(prefix, 100)
+		if len(got) != MaxSuggestions {
+			t.Fatalf("Suggest(%q, 100) returned %d suggestions, want %d", prefix, len(got), MaxSuggestions)
+		}
+		if got[0].Text != "lamp 29" || got[len(got)-1].Text != "lamp 20" {
+			t.Errorf("Suggest(%q, 100) = %q, want lamp 29 down to lamp 20", prefix, suggestionTexts(got))
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "the": true, "to": true,
+	"with": true, "without": true,
+}
+
+// Tokenize lowercases s and splits it on anything that isn't a letter or
+// digit.
+func Tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Terms tokenizes s, drops stopwords and stems what is left.
+func Terms(s string) []string {
+	tokens := Tokenize(s)
+	terms := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		if stopwords[token] {
+			continue
+		}
+		terms = append(terms, Stem(token))
+	}
+	return terms
+}
+
+// Stem strips common English inflections. It is deliberately light: the
+// fuzzy matcher picks up whatever it misses.
+func Stem(word string) string {
+	if len(word) <= 3 {
+		return word
+	}
+
+	switch {
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "sses"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "ing") && len(word) > 5:
+		return trimDouble(word[:len(word)-3])
+	case strings.HasSuffix(word, "ed") && len(word) > 4:
+		return trimDouble(word[:len(word)-2])
+	case strings.HasSuffix(word, "es") && hasSibilantBefore(word, 2):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us"):
+		return word[:len(word)-1]
+	}
+
+	return word
+}
+
+func hasSibilantBefore(word string, suffix int) bool {
+	stem := word[:len(word)-suffix]
+	for _, end := range []string{"s", "x", "z", "ch", "sh"} {
+		if strings.HasSuffix(stem, end) {
+			return true
+		}
+	}
+	return false
+}
+
+func trimDouble(stem string) string {
+	n := len(stem)
+	if n >= 2 && stem[n-1] == stem[n-2] && !strings.ContainsRune("lsz", rune(stem[n-1])) {
+		return stem[:n-1]
+	}
+	return stem
+}
@@ -0,0 +1,50 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"bus", "bus"},
+		{"shirts", "shirt"},
+		{"batteries", "battery"},
+		{"dresses", "dress"},
+		{"boxes", "box"},
+		{"watches", "watch"},
+		{"shoes", "shoe"},
+		{"running", "run"},
+		{"filling", "fill"},
+		{"printed", "print"},
+		{"glass", "glass"},
+		{"status", "status"},
+	}
+
+	for _, tt := range tests {
+		if got := Stem(tt.word); got != tt.want {
+			t.Errorf("Stem(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", []string{}},
+		{"The Running Shoes", []string{"run", "shoe"}},
+		{"T-Shirt, 2 pack", []string{"t", "shirt", "2", "pack"}},
+		{"Socks without holes", []string{"sock", "hole"}},
+	}
+
+	for _, tt := range tests {
+		if got := Terms(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Terms(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
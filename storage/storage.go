@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("stored file not found")
+	ErrInvalidKey = errors.New("invalid storage key")
+)
+
+// Object is an open stored file. Callers must close it.
+type Object struct {
+	io.ReadCloser
+	Size         int64
+	Content_Type string
+	Modified_At  time.Time
+}
+
+// Storage keeps uploaded files under slash-separated keys such as
+// "products/<id>/<image>.jpg".
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (*Object, error)
+	Delete(ctx context.Context, key string) error
+}
@@ -11,12 +11,13 @@ import (
 
 var SECRET_KEY = []byte("yoursecretkey")
 
-func GenerateAllTokens(email, firstName, lastName, userId string) (signedToken string, signedRefreshToken string, err error) {
+func GenerateAllTokens(email, firstName, lastName, userId, userType string) (signedToken string, signedRefreshToken string, err error) {
 	claims := jwt.MapClaims{
 		"email":      email,
 		"first_name": firstName,
 		"last_name":  lastName,
 		"user_id":    userId,
+		"user_type":  userType,
 		"exp":        time.Now().Add(time.Hour * 24).Unix(),
 	}
 